@@ -0,0 +1,124 @@
+package backend
+
+import (
+  "encoding/xml"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+var githubDataEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+var githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D",
+  "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// WriteGitHub writes cls as GitHub Actions workflow commands, which become
+// annotations on the changed lines when printed by a workflow step. Names are
+// made relative to GITHUB_WORKSPACE when it is set.
+func WriteGitHub(w io.Writer, cls []CompileLine) error {
+  workspace := os.Getenv("GITHUB_WORKSPACE")
+  for _, cl := range cls {
+    command := "error"
+    switch cl.Severity {
+    case SeverityWarning:
+      command = "warning"
+    case SeverityNote:
+      command = "notice"
+    }
+    props := make([]string, 0)
+    if cl.FileName != "" {
+      props = append(props, "file=" + githubPropertyEscaper.Replace(
+        workspaceRelative(workspace, cl.FileName)))
+      if cl.Line != 0 {
+        props = append(props, fmt.Sprintf("line=%d", cl.Line))
+      }
+      if cl.Line != 0 && cl.Column != 0 {
+        props = append(props, fmt.Sprintf("col=%d", cl.Column))
+      }
+    }
+    title := strings.TrimSpace(cl.Tool + " " + cl.Code)
+    if title != "" {
+      props = append(props, "title=" + githubPropertyEscaper.Replace(title))
+    }
+    line := "::" + command
+    if len(props) != 0 {
+      line += " " + strings.Join(props, ",")
+    }
+    _, err := fmt.Fprintf(w, "%s::%s\n", line, githubDataEscaper.Replace(
+      strings.TrimRight(cl.Message, "\n")))
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func workspaceRelative(workspace, name string) string {
+  if workspace == "" || !filepath.IsAbs(name) {
+    return name
+  }
+  rel, err := filepath.Rel(workspace, name)
+  if err != nil || strings.HasPrefix(rel, "..") {
+    return name
+  }
+  return filepath.ToSlash(rel)
+}
+
+type checkstyleError struct {
+  Line int `xml:"line,attr"`
+  Column int `xml:"column,attr,omitempty"`
+  Severity string `xml:"severity,attr"`
+  Message string `xml:"message,attr"`
+  Source string `xml:"source,attr,omitempty"`
+}
+
+type checkstyleFile struct {
+  Name string `xml:"name,attr"`
+  Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleReport struct {
+  XMLName xml.Name `xml:"checkstyle"`
+  Version string `xml:"version,attr"`
+  Files []*checkstyleFile `xml:"file"`
+}
+
+// WriteCheckstyle writes cls as a Checkstyle XML report, grouped by file.
+// Diagnostics without a file are left out as the format has no place for them.
+func WriteCheckstyle(w io.Writer, cls []CompileLine) error {
+  report := checkstyleReport{Version: "4.3"}
+  files := map[string]*checkstyleFile{}
+  for _, cl := range cls {
+    if cl.FileName == "" {
+      continue
+    }
+    f, ok := files[cl.FileName]
+    if !ok {
+      f = &checkstyleFile{Name: cl.FileName}
+      files[cl.FileName] = f
+      report.Files = append(report.Files, f)
+    }
+    severity := cl.Severity.String()
+    if cl.Severity == SeverityNote {
+      severity = "info"
+    }
+    source := cl.Tool
+    if cl.Code != "" {
+      source = strings.TrimPrefix(source + "." + cl.Code, ".")
+    }
+    f.Errors = append(f.Errors, checkstyleError{Line: cl.Line,
+      Column: cl.Column, Severity: severity, Message: cl.Message,
+      Source: source})
+  }
+  if _, err := io.WriteString(w, xml.Header); err != nil {
+    return err
+  }
+  enc := xml.NewEncoder(w)
+  enc.Indent("", "  ")
+  if err := enc.Encode(report); err != nil {
+    return err
+  }
+  _, err := io.WriteString(w, "\n")
+  return err
+}
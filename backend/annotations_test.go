@@ -0,0 +1,81 @@
+package backend
+
+import (
+  "bytes"
+  "encoding/xml"
+  "os"
+  "testing"
+)
+
+func TestWriteGitHub(t *testing.T) {
+  t.Setenv("GITHUB_WORKSPACE", "/work/repo")
+  cls := []CompileLine{
+    CompileLine{FileName: "/work/repo/cmd/main.go", Line: 3, Column: 7,
+      Tool: "go vet", Code: "printf", Severity: SeverityWarning,
+      Message: "100% wrong\nsee docs"},
+    CompileLine{FileName: "/elsewhere/a,b.go", Line: 1, Message: "bad"},
+    CompileLine{Severity: SeverityNote, Message: "done"},
+  }
+  var out bytes.Buffer
+  if err := WriteGitHub(&out, cls); err != nil {
+    t.Fatal(err)
+  }
+  expected := "::warning file=cmd/main.go,line=3,col=7,title=go vet printf::" +
+    "100%25 wrong%0Asee docs\n" +
+    "::error file=/elsewhere/a%2Cb.go,line=1::bad\n" +
+    "::notice::done\n"
+  if out.String() != expected {
+    t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+  }
+}
+
+func TestWriteCheckstyle(t *testing.T) {
+  cls := []CompileLine{
+    CompileLine{FileName: "a.go", Line: 3, Column: 7, Tool: "staticcheck",
+      Code: "SA4006", Severity: SeverityWarning, Message: "x < y"},
+    CompileLine{FileName: "b.go", Line: 1, Message: "undefined"},
+    CompileLine{FileName: "a.go", Line: 9, Severity: SeverityNote,
+      Message: "note"},
+    CompileLine{Message: "no file"},
+  }
+  var out bytes.Buffer
+  if err := WriteCheckstyle(&out, cls); err != nil {
+    t.Fatal(err)
+  }
+  t.Log(out.String())
+  report := checkstyleReport{}
+  if err := xml.Unmarshal(out.Bytes(), &report); err != nil {
+    t.Fatal(err)
+  }
+  if len(report.Files) != 2 || report.Files[0].Name != "a.go" ||
+    len(report.Files[0].Errors) != 2 || len(report.Files[1].Errors) != 1 {
+    t.Fatal("Report", report)
+  }
+  e := report.Files[0].Errors[0]
+  if e.Message != "x < y" || e.Source != "staticcheck.SA4006" ||
+    e.Severity != "warning" || e.Column != 7 {
+    t.Error("Error", e)
+  }
+  if report.Files[0].Errors[1].Severity != "info" {
+    t.Error("Expected notes as info, got", report.Files[0].Errors[1])
+  }
+}
+
+func TestExportStdout(t *testing.T) {
+  t.Setenv("GITHUB_WORKSPACE", "/work/repo")
+  var out bytes.Buffer
+  exportStdout = &out
+  defer func() { exportStdout = os.Stdout }()
+
+  cls := []CompileLine{CompileLine{FileName: "/work/repo/a.go", Line: 2,
+    Severity: SeverityError, Message: "bad"}}
+  if err := Export("github", "-", cls); err != nil {
+    t.Fatal(err)
+  }
+  if out.String() != "::error file=a.go,line=2::bad\n" {
+    t.Error("Expected annotation on stdout, got", out.String())
+  }
+  if _, err := os.Stat("-"); err == nil {
+    t.Error("Export to - wrote a file")
+  }
+}
@@ -1,20 +1,49 @@
 package backend
 
 import (
+  "context"
   "os/exec"
   "os"
   "strings"
   "errors"
   "fmt"
+  "time"
 )
 
 var ErrRoutineQuit = errors.New("Quit Continual Routine")
+// Returned with each line of output sent while a command is still running
+var ErrRoutineOutput = errors.New("Routine still running")
 
-type RoutineOut func()(string, error)
+type RoutineOut func()(*CommandOutput, error)
 
+// RunRoutine runs command, sending each line it writes to stdout or stderr on
+// result with ErrRoutineOutput as the line completes. The whole output and
+// the command's error are sent last.
 func RunRoutine(result chan<- RoutineOut, command *exec.Cmd) {
-  byte_output, err := command.CombinedOutput()
-  result <- (func()(string, error){ return string(byte_output), err })
+  recorder := newOutputRecorder(func(line OutputLine) {
+    chunk := &CommandOutput{Lines: []OutputLine{line}}
+    result <- (func()(*CommandOutput, error){ return chunk, ErrRoutineOutput })
+  })
+  command.Stdout = streamWriter{recorder, StreamStdout}
+  command.Stderr = streamWriter{recorder, StreamStderr}
+  err := command.Run()
+  output := recorder.finish()
+  result <- (func()(*CommandOutput, error){ return output, err })
+}
+
+// RoutineResult receives from result until the command finishes, passing
+// each chunk of output to chunk, which may be nil.
+func RoutineResult(result <-chan RoutineOut,
+  chunk func(*CommandOutput)) (*CommandOutput, error) {
+  for {
+    output, err := (<-result)()
+    if err != ErrRoutineOutput {
+      return output, err
+    }
+    if chunk != nil {
+      chunk(output)
+    }
+  }
 }
 
 func ContinualRoutine(result chan<- RoutineOut, quit <-chan bool,
@@ -22,7 +51,7 @@ func ContinualRoutine(result chan<- RoutineOut, quit <-chan bool,
   for {
     select {
     case _ = <-quit:
-      result <- (func()(string, error){ return "", ErrRoutineQuit })
+      result <- (func()(*CommandOutput, error){ return nil, ErrRoutineQuit })
       return
     case c := <-command:
       RunRoutine(result, c)
@@ -37,15 +66,31 @@ func ContinualRoutine(result chan<- RoutineOut, quit <-chan bool,
   }
 }
 
+var ErrTimedOut = errors.New("Command timed out")
+
 func RunInit(command, dir string, args, config_env []string) error {
-  c := exec.Command(command, args...)
+  return RunInitTimeout(command, dir, args, config_env, 0, 0)
+}
+
+// RunInitTimeout runs the init command, stopping its process group if it
+// takes longer than timeout. A timeout of zero means no limit.
+func RunInitTimeout(command, dir string, args, config_env []string,
+  timeout, grace time.Duration) error {
   envs, err := MakeEnvironment(config_env)
   if err != nil {
     return err
   }
-  c.Env = envs
-  c.Dir = dir
-  return c.Run()
+  ctx, cancel := context.WithCancel(context.Background())
+  if timeout > 0 {
+    ctx, cancel = context.WithTimeout(context.Background(), timeout)
+  }
+  defer cancel()
+  com := CommandDef{Name: command, Dir: dir, Args: args, Env: envs}
+  err = com.MakeCancellableRunnable(ctx, grace).Run()
+  if ctx.Err() == context.DeadlineExceeded {
+    return fmt.Errorf("%w after %s: %s", ErrTimedOut, timeout, com)
+  }
+  return err
 }
 
 func MakeEnvironment(add []string) ([]string, error) {
@@ -67,8 +112,28 @@ type CommandDef struct {
   Args, Env []string
 }
 
+// String gives the command line, quoted so it can be pasted into a shell.
 func (c CommandDef) String() string {
-  return c.Name + " " + strings.Join(c.Args, " ")
+  words := []string{ShellQuote(c.Name)}
+  for _, arg := range c.Args {
+    words = append(words, ShellQuote(arg))
+  }
+  return strings.Join(words, " ")
+}
+
+// ShellQuote quotes s for sh when it contains anything other than plain
+// characters.
+func ShellQuote(s string) string {
+  if s == "" {
+    return "''"
+  }
+  for _, r := range s {
+    if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+      strings.ContainsRune("@%+=:,./_-", r)) {
+      return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+    }
+  }
+  return s
 }
 
 func (c *CommandDef) MakeRunnable() *exec.Cmd {
@@ -77,3 +142,16 @@ func (c *CommandDef) MakeRunnable() *exec.Cmd {
   runnable.Dir = c.Dir
   return runnable
 }
+
+// MakeCancellableRunnable creates the command to run in its own process
+// group, which is stopped when ctx is done. After grace, output is no longer
+// waited on.
+func (c *CommandDef) MakeCancellableRunnable(ctx context.Context,
+  grace time.Duration) *exec.Cmd {
+  runnable := exec.CommandContext(ctx, c.Name, c.Args...)
+  runnable.Env = c.Env
+  runnable.Dir = c.Dir
+  setProcessGroup(runnable, grace)
+  runnable.WaitDelay = grace
+  return runnable
+}
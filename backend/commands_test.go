@@ -1,19 +1,23 @@
 package backend
 
 import (
+  "context"
+  "errors"
   "testing"
   "os"
   "os/exec"
   "strings"
   "io/ioutil"
   "bufio"
+  "time"
 )
 
 func TestRoutineBasic(t *testing.T) {
   c := exec.Command("echo", "Hello, world!")
   out_chan := make(chan RoutineOut)
   go RunRoutine(out_chan, c)
-  res_string, err := (<-out_chan)()
+  output, err := RoutineResult(out_chan, nil)
+  res_string := output.String()
   t.Log(strings.TrimSpace(res_string))
   t.Log(err)
   if (res_string != "Hello, world!\n") || (err != nil) {
@@ -25,7 +29,8 @@ func TestRoutineNoCommand(t *testing.T) {
   c := exec.Command("echo_but_not", "Hello, world!")
   out_chan := make(chan RoutineOut)
   go RunRoutine(out_chan, c)
-  res_string, err := (<-out_chan)()
+  output, err := RoutineResult(out_chan, nil)
+  res_string := output.String()
   t.Log(strings.TrimSpace(res_string))
   t.Log(err)
   if err == nil {
@@ -45,7 +50,8 @@ func TestRoutineCD(t *testing.T) {
   c.Dir = "/tmp/go_tests"
   out_chan := make(chan RoutineOut)
   go RunRoutine(out_chan, c)
-  res_string, err := (<-out_chan)()
+  output, err := RoutineResult(out_chan, nil)
+  res_string := output.String()
   t.Log(strings.TrimSpace(res_string))
   t.Log(err)
   if (res_string != "A test file\n") || (err != nil) {
@@ -53,6 +59,30 @@ func TestRoutineCD(t *testing.T) {
   }
 }
 
+func TestRoutineChunks(t *testing.T) {
+  c := exec.Command("sh", "-c", "echo out; sleep 0.1; echo err >&2; printf end")
+  out_chan := make(chan RoutineOut)
+  go RunRoutine(out_chan, c)
+  chunks := make([]string, 0)
+  output, err := RoutineResult(out_chan, func(chunk *CommandOutput) {
+    chunks = append(chunks, chunk.String())
+  })
+  t.Log(chunks)
+  if err != nil || output.String() != "out\nerr\nend" {
+    t.Error("Result", output, err)
+  }
+  if len(chunks) != 3 || chunks[0] != "out\n" || chunks[1] != "err\n" {
+    t.Error("Expected chunks out, err and end, got", chunks)
+  }
+  if output.Text(StreamStdout) != "out\nend" || output.Text(StreamStderr) != "err\n" {
+    t.Error("Streams", output.Text(StreamStdout), output.Text(StreamStderr))
+  }
+  if len(output.Lines) != 3 || output.Lines[1].Stream != StreamStderr ||
+    output.Lines[1].Time.Before(output.Lines[0].Time) {
+    t.Error("Lines", output.Lines)
+  }
+}
+
 func TestContinual(t *testing.T) {
   out_chan := make(chan RoutineOut)
   quit_chan := make(chan bool)
@@ -61,7 +91,8 @@ func TestContinual(t *testing.T) {
   for i := 0; i != 2; i++ {
     c := exec.Command("echo", "Hello, world!")
     com_chan <- c
-    res_string, err := (<-out_chan)()
+    output, err := RoutineResult(out_chan, nil)
+    res_string := output.String()
     t.Log(strings.TrimSpace(res_string))
     t.Log(err)
     if (res_string != "Hello, world!\n") || (err != nil) {
@@ -69,7 +100,8 @@ func TestContinual(t *testing.T) {
     }
   }
   quit_chan <- true
-  res_string, err := (<-out_chan)()
+  output, err := RoutineResult(out_chan, nil)
+  res_string := output.String()
   t.Log(strings.TrimSpace(res_string))
   t.Log(err)
   if (res_string != "" || err != ErrRoutineQuit) {
@@ -163,6 +195,14 @@ func TestCommandDefString(t *testing.T) {
   }
 }
 
+func TestCommandDefQuoting(t *testing.T) {
+  c := CommandDef{Name: "sh", Args: []string{"-c", "make && echo 'done'", ""}}
+  expected := `sh -c 'make && echo '\''done'\''' ''`
+  if c.String() != expected {
+    t.Error("Command string", c.String(), "expected:", expected)
+  }
+}
+
 func TestMakeRunnable(t *testing.T) {
   craw := CommandDef{"hello", "world", []string{"these", "are", "args"}, []string{"this", "is", "env"}}
   c := craw.MakeRunnable()
@@ -209,3 +249,41 @@ func resetEnv(env []string) {
     os.Setenv(kv_slice[0], kv_slice[1])
   }
 }
+
+func TestCancellableRunnable(t *testing.T) {
+  // The child ignores SIGTERM, so the group must be killed after the grace
+  craw := CommandDef{Name: "sh", Args: []string{"-c",
+    "sh -c 'trap \"\" TERM; sleep 10' & echo started; wait"}}
+  ctx, cancel := context.WithCancel(context.Background())
+  c := craw.MakeCancellableRunnable(ctx, 200 * time.Millisecond)
+  out_chan := make(chan RoutineOut)
+  go RunRoutine(out_chan, c)
+  start := time.Now()
+  output, err := RoutineResult(out_chan, func(chunk *CommandOutput) {
+    cancel()
+  })
+  t.Log(output, err, time.Since(start))
+  if err == nil || ctx.Err() == nil {
+    t.Error("Expected cancelled command to fail, got", err)
+  }
+  if time.Since(start) > 5 * time.Second {
+    t.Error("Cancelled command took", time.Since(start))
+  }
+}
+
+func TestRunInitTimeout(t *testing.T) {
+  start := time.Now()
+  err := RunInitTimeout("sleep", ".", []string{"10"}, []string{},
+    100 * time.Millisecond, 100 * time.Millisecond)
+  t.Log(err, time.Since(start))
+  if !errors.Is(err, ErrTimedOut) {
+    t.Error("Expected ErrTimedOut, got", err)
+  }
+  if time.Since(start) > 5 * time.Second {
+    t.Error("Timed out command took", time.Since(start))
+  }
+  err = RunInitTimeout("true", ".", []string{}, []string{}, time.Second, 0)
+  if err != nil {
+    t.Error(err)
+  }
+}
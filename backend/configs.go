@@ -2,18 +2,31 @@ package backend
 
 import (
   "github.com/spf13/viper"
+  "errors"
   "fmt"
+  "path/filepath"
+  "regexp"
+  "strings"
+  "time"
 )
 
-var required_confs = [...]string {"PeriodicCommand.command"}
+// Each required field may be given in any one of several forms
+var required_confs = [...][]string {
+  {"PeriodicCommand.command", "PeriodicCommand.shell", "PeriodicCommand.steps",
+    "Commands"},
+}
 var default_confs = map[string]interface{} {"Init.dir": "/tmp/coco",
                                            "PeriodicCommand.dir": "/tmp/coco"}
 
 func checkRequired(viper *viper.Viper) error {
-  for _, val := range required_confs {
-    if !viper.IsSet(val) {
-      return fmt.Errorf("Did not find required field %s in config file %s", val,
-                        viper.ConfigFileUsed())
+  for _, vals := range required_confs {
+    found := false
+    for _, val := range vals {
+      found = found || viper.IsSet(val)
+    }
+    if !found {
+      return fmt.Errorf("Did not find required field %s in config file %s",
+                        strings.Join(vals, " or "), viper.ConfigFileUsed())
     }
   }
   return nil
@@ -81,16 +94,222 @@ func GetCommandMode(viper *viper.Viper) CommandMode {
   }
 }
 
+var ErrUnknownPolicy = errors.New("Unknown RunOn.on_change policy")
+
+// ChangePolicy decides what happens to a running command when changes arrive.
+type ChangePolicy int
+
+const (
+  // Run again once the current run finishes
+  QueueChanges ChangePolicy = iota
+  // Stop the current run and start again
+  RestartOnChange
+  // Drop changes made while running
+  IgnoreChanges
+)
+
+func ReadChangePolicy(viper *viper.Viper) (ChangePolicy, error) {
+  switch policy := viper.GetString("RunOn.on_change"); policy {
+  case "", "queue":
+    return QueueChanges, nil
+  case "restart":
+    return RestartOnChange, nil
+  case "ignore":
+    return IgnoreChanges, nil
+  default:
+    return QueueChanges, fmt.Errorf("%w: %s", ErrUnknownPolicy, policy)
+  }
+}
+
+// ReadMinSeverity returns the least severe diagnostic to show, from
+// Parser.min_severity or else notes.
+func ReadMinSeverity(viper *viper.Viper) (Severity, error) {
+  if !viper.IsSet("Parser.min_severity") {
+    return SeverityNote, nil
+  }
+  return ParseSeverity(viper.GetString("Parser.min_severity"))
+}
+
+// ReadGracePeriod returns how long stopped commands have to exit after
+// SIGTERM before they are killed, RunOn.grace_period seconds or else 5.
+func ReadGracePeriod(viper *viper.Viper) time.Duration {
+  seconds := 5.0
+  if viper.IsSet("RunOn.grace_period") {
+    seconds = viper.GetFloat64("RunOn.grace_period")
+  }
+  return time.Duration(seconds * float64(time.Second))
+}
+
+// ReadTimeout returns the time limit for runs of the command in section, from
+// its timeout in seconds, or zero when there is none.
+func ReadTimeout(viper *viper.Viper, section string) time.Duration {
+  return time.Duration(viper.GetFloat64(section + ".timeout") *
+    float64(time.Second))
+}
+
+var ErrShellAndCommand = errors.New("Both shell and command are set")
+var ErrEmptyShell = errors.New("Shell is set but empty")
+
+// ReadShell returns the shell which runs shell scripts, from Shell as a list
+// or a single string split on spaces. Defaults to sh -c.
+func ReadShell(viper *viper.Viper) ([]string, error) {
+  if !viper.IsSet("Shell") {
+    return []string{"sh", "-c"}, nil
+  }
+  shell := viper.GetStringSlice("Shell")
+  if len(shell) == 0 {
+    return nil, ErrEmptyShell
+  }
+  return shell, nil
+}
+
+// ReadCommandLine returns the command and args of section, either as given
+// or running its shell script with the shell.
+func ReadCommandLine(viper *viper.Viper, section string) (string, []string,
+  error) {
+  script := viper.GetString(section + ".shell")
+  if script == "" {
+    return viper.GetString(section + ".command"),
+      viper.GetStringSlice(section + ".args"), nil
+  }
+  if viper.GetString(section + ".command") != "" {
+    return "", nil, fmt.Errorf("%w in %s", ErrShellAndCommand, section)
+  }
+  shell, err := ReadShell(viper)
+  if err != nil {
+    return "", nil, err
+  }
+  args := append(append([]string{}, shell[1:]...), script)
+  return shell[0], args, nil
+}
+
+var envAssignReg = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// scriptCommand guesses what PeriodicCommand runs, taking the first command
+// of a shell script, for naming it and choosing a parser. Leading cd dir &&
+// and VAR=value words are passed over, anything more involved needs a name
+// and Parser.format set.
+func scriptCommand(viper *viper.Viper) CommandDef {
+  if viper.GetString("PeriodicCommand.shell") == "" {
+    return CommandDef{Name: viper.GetString("PeriodicCommand.command"),
+      Args: viper.GetStringSlice("PeriodicCommand.args")}
+  }
+  words := strings.Fields(viper.GetString("PeriodicCommand.shell"))
+  for len(words) != 0 {
+    if envAssignReg.MatchString(words[0]) {
+      words = words[1:]
+    } else if words[0] == "cd" && len(words) > 1 &&
+      strings.HasSuffix(words[1], ";") {
+      words = words[2:]
+    } else if words[0] == "cd" && len(words) > 2 &&
+      (words[2] == "&&" || words[2] == ";") {
+      words = words[3:]
+    } else {
+      break
+    }
+  }
+  if len(words) == 0 {
+    return CommandDef{Name: "shell"}
+  }
+  return CommandDef{Name: words[0], Args: words[1:]}
+}
+
 func ReadPeriodicCommand(viper *viper.Viper) (CommandDef, error) {
-  com := CommandDef{Name: viper.GetString("PeriodicCommand.command"),
+  name, args, err := ReadCommandLine(viper, "PeriodicCommand")
+  if err != nil {
+    return CommandDef{}, err
+  }
+  com := CommandDef{Name: name,
   Dir: viper.GetString("PeriodicCommand.dir"),
-  Args: viper.GetStringSlice("PeriodicCommand.args"), Env: []string{}}
-  var err error
+  Args: args, Env: []string{}}
   com.Env, err = MakeEnvironment(
     viper.GetStringSlice("PeriodicCommand.env"))
   return com, err
 }
 
+// readErrorformat accepts Parser.errorformat either as a single Vim style
+// comma separated string, or as a list with one format per item.
+func readErrorformat(viper *viper.Viper) string {
+  if list, ok := viper.Get("Parser.errorformat").([]interface{}); ok {
+    formats := make([]string, 0, len(list))
+    for _, v := range list {
+      formats = append(formats, strings.ReplaceAll(fmt.Sprint(v), ",", `\,`))
+    }
+    return strings.Join(formats, ",")
+  }
+  return viper.GetString("Parser.errorformat")
+}
+
+// ReadParser builds the parser described by the Parser section. Parsers which
+// look at stack traces are given Parser.root as the project root.
+func ReadParser(viper *viper.Viper) (Parser, error) {
+  p, err := readParser(viper)
+  if err != nil {
+    return nil, err
+  }
+  if r, ok := p.(rootedParser); ok && viper.IsSet("Parser.root") {
+    p = r.withRoot(viper.GetString("Parser.root"))
+  }
+  return p, nil
+}
+
+func readParser(viper *viper.Viper) (Parser, error) {
+  if viper.IsSet("Parser.pattern") {
+    tool := filepath.Base(scriptCommand(viper).Name)
+    return NewRegexParser(tool, viper.GetString("Parser.pattern"),
+      viper.GetString("Parser.continuation"))
+  }
+  if viper.IsSet("Parser.errorformat") {
+    tool := filepath.Base(scriptCommand(viper).Name)
+    return NewErrorformatParser(tool, readErrorformat(viper))
+  }
+  if viper.IsSet("Parser.format") {
+    return GetParser(viper.GetString("Parser.format"))
+  }
+  com_def := scriptCommand(viper)
+  if IsGoTestJSON(com_def) {
+    return GetParser("gotest")
+  } else if IsGoVetJSON(com_def) {
+    return GetParser("govet")
+  }
+  // Commands named after a format or one of its aliases imply it
+  if p, err := GetParser(filepath.Base(com_def.Name)); err == nil {
+    return p, nil
+  }
+  return GetParser("gcc")
+}
+
+// ReadPathResolver resolves names against PeriodicCommand.dir, falling back
+// to Parser.root or else RunOn.fs_root as the project root.
+func ReadPathResolver(viper *viper.Viper) *PathResolver {
+  dir := viper.GetString("PeriodicCommand.dir")
+  root := dir
+  if viper.IsSet("Parser.root") {
+    root = viper.GetString("Parser.root")
+  } else if viper.IsSet("RunOn.fs_root") {
+    root = viper.GetString("RunOn.fs_root")
+  }
+  return NewPathResolver(root, dir)
+}
+
+// ReadExports returns the file each configured Export format should be written
+// to, relative to PeriodicCommand.dir, or - for stdout.
+func ReadExports(viper *viper.Viper) (map[string]string, error) {
+  exports := map[string]string{}
+  dir := viper.GetString("PeriodicCommand.dir")
+  for format, name := range viper.GetStringMapString("Export") {
+    if _, ok := exporters[format]; !ok {
+      return nil, fmt.Errorf("%w: %s, expected one of %s", ErrUnknownExport,
+        format, strings.Join(exportNames(), ", "))
+    }
+    if name != "-" && !filepath.IsAbs(name) {
+      name = filepath.Join(dir, name)
+    }
+    exports[format] = name
+  }
+  return exports, nil
+}
+
 /*
 Init:
   command : cmake
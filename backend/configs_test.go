@@ -4,6 +4,9 @@ import (
   "testing"
   "io"
   "os"
+  "errors"
+  "time"
+  "github.com/spf13/viper"
 )
 
 func testSetup(config_file string, t *testing.T) {
@@ -54,7 +57,7 @@ func TestConfig(t *testing.T) {
   testSetup("test_config.yaml", t)
   defer testTeardown(t)
 
-  viper, err := ReadConfig([]string{})
+  viper, err := ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -100,7 +103,7 @@ func TestDefaults(t *testing.T) {
   testSetup("defaults_config.yaml", t)
   defer testTeardown(t)
 
-  viper, err := ReadConfig([]string{})
+  viper, err := ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -118,7 +121,7 @@ func TestBadConfig(t *testing.T) {
   testSetup("bad_config.yaml", t)
   defer testTeardown(t)
 
-  _, err := ReadConfig([]string{})
+  _, err := ReadConfig("config", []string{})
   t.Log(err)
   if err == nil {
     t.Fail()
@@ -129,7 +132,7 @@ func TestAddPath(t *testing.T) {
   extTestSetup("test_config.yaml", "/tmp/", t)
   defer extTeardown("/tmp/", t)
 
-  viper, err := ReadConfig([]string{"/tmp"})
+  viper, err := ReadConfig("config", []string{"/tmp"})
 
   if err != nil {
     t.Error(err)
@@ -142,7 +145,7 @@ func TestAddPath(t *testing.T) {
 }
 
 func TestNoConfig(t *testing.T) {
-  _, err := ReadConfig([]string{})
+  _, err := ReadConfig("config", []string{})
   t.Log(err)
   if err == nil {
     t.Fail()
@@ -153,7 +156,7 @@ func TestCommandModeTime(t *testing.T) {
   testSetup("t_conf_1.yaml", t)
   defer testTeardown(t)
 
-  viper, err := ReadConfig([]string{})
+  viper, err := ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -164,7 +167,7 @@ func TestCommandModeTime(t *testing.T) {
 
   testSetup("t_conf_2.yaml", t)
 
-  viper, err = ReadConfig([]string{})
+  viper, err = ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -175,7 +178,7 @@ func TestCommandModeTime(t *testing.T) {
 
   testSetup("t_conf_3.yaml", t)
 
-  viper, err = ReadConfig([]string{})
+  viper, err = ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -189,7 +192,7 @@ func TestCommandModeFS(t *testing.T) {
   testSetup("fs_conf_1.yaml", t)
   defer testTeardown(t)
 
-  viper, err := ReadConfig([]string{})
+  viper, err := ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -200,7 +203,7 @@ func TestCommandModeFS(t *testing.T) {
 
   testSetup("fs_conf_2.yaml", t)
 
-  viper, err = ReadConfig([]string{})
+  viper, err = ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -211,7 +214,7 @@ func TestCommandModeFS(t *testing.T) {
 
   testSetup("fs_conf_3.yaml", t)
 
-  viper, err = ReadConfig([]string{})
+  viper, err = ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -225,7 +228,7 @@ func TestCommandModeInvalid(t *testing.T) {
   testSetup("inv_conf_1.yaml", t)
   defer testTeardown(t)
 
-  viper, err := ReadConfig([]string{})
+  viper, err := ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -236,7 +239,7 @@ func TestCommandModeInvalid(t *testing.T) {
 
   testSetup("inv_conf_2.yaml", t)
 
-  viper, err = ReadConfig([]string{})
+  viper, err = ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -250,7 +253,7 @@ func TestPeriodicCommand(t *testing.T) {
   testSetup("test_config.yaml", t)
   defer testTeardown(t)
 
-  viper, err := ReadConfig([]string{})
+  viper, err := ReadConfig("config", []string{})
   if err != nil {
     t.Error(err)
   }
@@ -264,3 +267,452 @@ func TestPeriodicCommand(t *testing.T) {
     t.Error("Created incorrect command")
   }
 }
+
+func TestReadParser(t *testing.T) {
+  testSetup("parser_conf.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Error(err)
+  }
+
+  p, err := ReadParser(viper)
+  if err != nil {
+    t.Error(err)
+  }
+  if p != parsers["gcc"] {
+    t.Error("Expected gcc parser")
+  }
+
+  viper.Set("Parser.format", "nonsense")
+  _, err = ReadParser(viper)
+  t.Log(err)
+  if err == nil {
+    t.Error("Expected error for unknown format")
+  }
+}
+
+func TestReadParserDefault(t *testing.T) {
+  testSetup("defaults_config.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Error(err)
+  }
+
+  viper.Set("PeriodicCommand.command", "/usr/bin/cargo")
+  p, err := ReadParser(viper)
+  if err != nil || p != parsers["rustc"] {
+    t.Error("Expected rustc parser for cargo")
+  }
+
+  // Commands and format names share one set of aliases
+  commands := map[string]string{"java": "jvm", "python3": "python",
+    "npx": "gcc", "make": "gcc"}
+  for command, format := range commands {
+    viper.Set("PeriodicCommand.command", command)
+    p, err = ReadParser(viper)
+    f, _ := GetParser(command)
+    if err != nil || p != parsers[format] || (f != nil && f != p) {
+      t.Error("Expected", format, "parser for", command, "got", p)
+    }
+  }
+}
+
+func TestReadParserPattern(t *testing.T) {
+  testSetup("pattern_conf.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Error(err)
+  }
+
+  p, err := ReadParser(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  cls := p.Parse("[ERROR] gen/a.x line 3: oops\n> more\n")
+  if len(cls) != 1 || cls[0].FileName != "gen/a.x" || cls[0].Message != "oops\n> more" {
+    t.Error("Pattern parser returned", cls)
+  }
+}
+
+func TestReadParserErrorformat(t *testing.T) {
+  testSetup("efm_conf.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Error(err)
+  }
+
+  p, err := ReadParser(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  cls := p.Parse("a.c:3: error, bad\n    ^\n")
+  t.Log(cls)
+  if len(cls) != 1 || cls[0].FileName != "a.c" || cls[0].Column != 5 ||
+    cls[0].Message != "bad" {
+    t.Error("Errorformat parser returned", cls)
+  }
+}
+
+func TestReadParserRoot(t *testing.T) {
+  testSetup("defaults_config.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Error(err)
+  }
+
+  viper.Set("Parser.format", "go")
+  viper.Set("Parser.root", "/home/dev")
+  p, err := ReadParser(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if gp, ok := p.(goParser); !ok || gp.panics.root != "/home/dev" {
+    t.Error("Expected go parser rooted at /home/dev, got", p)
+  }
+}
+
+func TestReadExports(t *testing.T) {
+  testSetup("export_conf.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exports, err := ReadExports(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(exports) != 2 || exports["sarif"] != "/tmp/coco/coco.sarif" ||
+    exports["github"] != "-" {
+    t.Error("Expected sarif export to /tmp/coco/coco.sarif and github to -, got",
+      exports)
+  }
+
+  viper.Set("Export.nope", "out")
+  _, err = ReadExports(viper)
+  if !errors.Is(err, ErrUnknownExport) {
+    t.Error("Expected ErrUnknownExport, got", err)
+  }
+}
+
+func TestReadMinSeverity(t *testing.T) {
+  testSetup("fs_conf_1.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  severity, err := ReadMinSeverity(viper)
+  if err != nil || severity != SeverityNote {
+    t.Error("Expected default of notes, got", severity, err)
+  }
+  viper.Set("Parser.min_severity", "warning")
+  severity, err = ReadMinSeverity(viper)
+  if err != nil || severity != SeverityWarning {
+    t.Error("Expected warnings, got", severity, err)
+  }
+  viper.Set("Parser.min_severity", "warnig")
+  if _, err = ReadMinSeverity(viper); !errors.Is(err, ErrUnknownSeverity) {
+    t.Error("Expected ErrUnknownSeverity, got", err)
+  }
+}
+
+func TestReadChangePolicy(t *testing.T) {
+  testSetup("fs_conf_1.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  policy, err := ReadChangePolicy(viper)
+  if err != nil || policy != QueueChanges {
+    t.Error("Expected default queue policy, got", policy, err)
+  }
+  if ReadGracePeriod(viper) != 5 * time.Second {
+    t.Error("Expected default grace period of 5s, got", ReadGracePeriod(viper))
+  }
+
+  viper.Set("RunOn.on_change", "restart")
+  viper.Set("RunOn.grace_period", 0.5)
+  policy, err = ReadChangePolicy(viper)
+  if err != nil || policy != RestartOnChange {
+    t.Error("Expected restart policy, got", policy, err)
+  }
+  if ReadGracePeriod(viper) != 500 * time.Millisecond {
+    t.Error("Expected grace period of 0.5s, got", ReadGracePeriod(viper))
+  }
+
+  if ReadTimeout(viper, "PeriodicCommand") != 0 {
+    t.Error("Expected no timeout, got", ReadTimeout(viper, "PeriodicCommand"))
+  }
+  viper.Set("Init.timeout", 90)
+  if ReadTimeout(viper, "Init") != 90 * time.Second {
+    t.Error("Expected 90s init timeout, got", ReadTimeout(viper, "Init"))
+  }
+
+  viper.Set("RunOn.on_change", "sometimes")
+  _, err = ReadChangePolicy(viper)
+  if !errors.Is(err, ErrUnknownPolicy) {
+    t.Error("Expected ErrUnknownPolicy, got", err)
+  }
+}
+
+func TestReadPipeline(t *testing.T) {
+  testSetup("steps_conf.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  pipeline, err := ReadPipeline(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if pipeline.StopOnFailure || len(pipeline.Steps) != 4 {
+    t.Fatal("Pipeline", pipeline)
+  }
+  names := []string{"generate", "gcc", "test", "lint"}
+  for i, step := range pipeline.Steps {
+    if step.Name != names[i] {
+      t.Error("Step", i, "named", step.Name, "expected", names[i])
+    }
+  }
+  gen := pipeline.Steps[0]
+  if gen.Command.Dir != "/tmp/coco" || len(gen.Command.Args) != 2 {
+    t.Error("Generate step", gen.Command)
+  }
+  if gp, ok := gen.Parser.(goParser); !ok || gp.panics.root != "/src/project" {
+    t.Error("Expected rooted go parser for generate, got", gen.Parser)
+  }
+  gcc := pipeline.Steps[1]
+  if gcc.Command.Dir != "/tmp/coco/build" || len(gcc.Command.Args) != 0 {
+    t.Error("gcc step", gcc.Command)
+  }
+  if _, ok := pipeline.Steps[2].Parser.(goTestParser); !ok {
+    t.Error("Expected go test parser, got", pipeline.Steps[2].Parser)
+  }
+  if _, ok := pipeline.Steps[3].Parser.(*regexParser); !ok {
+    t.Error("Expected pattern parser for lint, got", pipeline.Steps[3].Parser)
+  }
+  if gen.Stream != StreamCombined || pipeline.Steps[3].Stream != StreamStderr {
+    t.Error("Expected stderr for lint only, got", gen.Stream,
+      pipeline.Steps[3].Stream)
+  }
+}
+
+func TestReadPipelineSingle(t *testing.T) {
+  testSetup("t_conf_1.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  pipeline, err := ReadPipeline(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !pipeline.StopOnFailure || len(pipeline.Steps) != 1 ||
+    pipeline.Steps[0].Name != "real-command" ||
+    pipeline.Steps[0].Command.Dir != "/tmp/commands" {
+    t.Error("Pipeline", pipeline)
+  }
+}
+
+func TestReadPipelines(t *testing.T) {
+  testSetup("commands_conf.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  pipelines, err := ReadPipelines(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(pipelines) != 3 {
+    t.Fatal("Read", len(pipelines), "pipelines, expected 3")
+  }
+  linux := pipelines[0]
+  if linux.Name != "linux" || len(linux.Steps) != 1 ||
+    linux.Steps[0].Command.Dir != "/tmp/coco" ||
+    linux.Steps[0].Command.Args[0] != "TARGET=linux" {
+    t.Error("linux", linux)
+  }
+  arm := pipelines[1]
+  if arm.Name != "arm" || len(arm.Steps) != 2 ||
+    arm.Steps[1].Command.Dir != "/tmp/coco/arm" {
+    t.Error("arm", arm)
+  }
+  if linux.Timeout != 30 * time.Second || arm.Timeout != 5 * time.Second {
+    t.Error("Expected timeouts of 30s and 5s, got", linux.Timeout, arm.Timeout)
+  }
+  if _, ok := arm.Steps[1].Parser.(goTestParser); !ok {
+    t.Error("Expected go test parser for arm test, got", arm.Steps[1].Parser)
+  }
+  lint := pipelines[2]
+  if lint.Name != "golangci-lint" {
+    t.Error("Expected lint named after its command, got", lint.Name)
+  }
+  if _, ok := lint.Steps[0].Parser.(gccParser); !ok {
+    t.Error("Expected gcc parser for lint, got", lint.Steps[0].Parser)
+  }
+}
+
+func TestReadPipelineGraph(t *testing.T) {
+  testSetup("graph_conf.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  pipeline, err := ReadPipeline(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !pipeline.Graph() || len(pipeline.Steps) != 5 ||
+    len(pipeline.Steps[3].Needs) != 2 {
+    t.Fatal("Pipeline", pipeline)
+  }
+
+  states := make([]StepState, 5)
+  ready := pipeline.Schedule(states)
+  if len(ready) != 2 || ready[0] != 0 || ready[1] != 4 {
+    t.Fatal("Expected generate and lint ready first, got", ready)
+  }
+  states[0], states[4] = StepPassed, StepFailed
+  ready = pipeline.Schedule(states)
+  if len(ready) != 2 || ready[0] != 1 || ready[1] != 2 {
+    t.Fatal("Expected build and vet ready after generate, got", ready)
+  }
+  states[1], states[2] = StepFailed, StepRunning
+  ready = pipeline.Schedule(states)
+  if len(ready) != 0 || states[3] != StepSkipped {
+    t.Error("Expected test skipped after build failed, got", ready, states)
+  }
+  status := pipeline.GraphStatus(states)
+  if status != "generate ok, build failed, vet running, test skipped, lint failed" {
+    t.Error("Status", status)
+  }
+
+  viper.Set("PeriodicCommand.steps", []map[string]interface{}{
+    {"name": "a", "command": "true", "needs": []string{"b"}},
+    {"name": "b", "command": "true", "needs": []string{"a"}},
+    {"name": "c", "command": "true"}})
+  _, err = ReadPipeline(viper)
+  if !errors.Is(err, ErrNeedsCycle) {
+    t.Error("Expected ErrNeedsCycle, got", err)
+  }
+  viper.Set("PeriodicCommand.steps", []map[string]interface{}{
+    {"name": "a", "command": "true", "needs": []string{"missing"}}})
+  _, err = ReadPipeline(viper)
+  if !errors.Is(err, ErrUnknownNeed) {
+    t.Error("Expected ErrUnknownNeed, got", err)
+  }
+  viper.Set("PeriodicCommand.steps", []map[string]interface{}{
+    {"name": "a", "command": "true"},
+    {"name": "a", "command": "false", "needs": []string{"a"}}})
+  _, err = ReadPipeline(viper)
+  if !errors.Is(err, ErrDuplicateStep) {
+    t.Error("Expected ErrDuplicateStep, got", err)
+  }
+}
+
+func TestReadShell(t *testing.T) {
+  testSetup("shell_conf.yaml", t)
+  defer testTeardown(t)
+
+  viper, err := ReadConfig("config", []string{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  com, err := ReadPeriodicCommand(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if com.Name != "bash" || len(com.Args) != 4 ||
+    com.Args[3] != "go vet -json ./... 2>&1 | tee vet.log" {
+    t.Error("Periodic command", com)
+  }
+  pipeline, err := ReadPipeline(viper)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if pipeline.Steps[0].Name != "go" {
+    t.Error("Expected step named after the script, got", pipeline.Steps[0].Name)
+  }
+  if _, ok := pipeline.Steps[0].Parser.(vetParser); !ok {
+    t.Error("Expected go vet parser, got", pipeline.Steps[0].Parser)
+  }
+  if !IsGoVetJSON(pipeline.Steps[0].Script) {
+    t.Error("Expected the script's command, got", pipeline.Steps[0].Script)
+  }
+
+  viper.Set("Shell", []string{"sh", "-c"})
+  name, args, err := ReadCommandLine(viper, "Init")
+  if err != nil || name != "sh" || len(args) != 2 || args[0] != "-c" ||
+    args[1] != "mkdir -p build && touch build/stamp" {
+    t.Error("Init command", name, args, err)
+  }
+
+  viper.Set("Shell", []string{})
+  _, _, err = ReadCommandLine(viper, "Init")
+  if !errors.Is(err, ErrEmptyShell) {
+    t.Error("Expected ErrEmptyShell, got", err)
+  }
+
+  viper.Set("Init.command", "make")
+  _, _, err = ReadCommandLine(viper, "Init")
+  if !errors.Is(err, ErrShellAndCommand) {
+    t.Error("Expected ErrShellAndCommand, got", err)
+  }
+}
+
+func TestScriptCommand(t *testing.T) {
+  scripts := map[string]string{
+    "cd build && make -j4": "make",
+    "cd build; ninja": "ninja",
+    "FOO=1 BAR=x cargo build": "cargo",
+    "cd sub && CGO_ENABLED=0 go build ./...": "go",
+    "true": "true",
+  }
+  for script, name := range scripts {
+    v := viper.New()
+    v.Set("PeriodicCommand.shell", script)
+    if c := scriptCommand(v); c.Name != name {
+      t.Error("Script", script, "runs", c.Name, "expected", name)
+    }
+  }
+  name, _, err := ReadCommandLine(viper.New(), "Init")
+  if err != nil || name != "" {
+    t.Error("Expected no init command, got", name, err)
+  }
+  shell, err := ReadShell(viper.New())
+  if err != nil || len(shell) != 2 || shell[0] != "sh" {
+    t.Error("Expected sh -c by default, got", shell, err)
+  }
+}
@@ -0,0 +1,258 @@
+package backend
+
+import (
+  "bufio"
+  "bytes"
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path"
+  "path/filepath"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+var ErrBadCoverage = errors.New("Invalid coverage profile")
+
+// FileCoverage counts the statements, or lines for lcov, covered in one file
+// or package.
+type FileCoverage struct {
+  Name string
+  Statements int
+  Covered int
+}
+
+// Percent returns the percentage of statements covered, 100 when there are
+// none.
+func (f FileCoverage) Percent() float64 {
+  if f.Statements == 0 {
+    return 100
+  }
+  return 100 * float64(f.Covered) / float64(f.Statements)
+}
+
+type CoverageReport struct {
+  // Files sorted by name
+  Files []FileCoverage
+}
+
+// CoveragePackage returns the package, or directory, a file belongs to.
+func CoveragePackage(name string) string {
+  return path.Dir(filepath.ToSlash(name))
+}
+
+// Packages sums file coverage by directory, sorted by name.
+func (r *CoverageReport) Packages() []FileCoverage {
+  pkgs := map[string]*FileCoverage{}
+  names := make([]string, 0)
+  for _, f := range r.Files {
+    dir := CoveragePackage(f.Name)
+    pkg, ok := pkgs[dir]
+    if !ok {
+      pkg = &FileCoverage{Name: dir}
+      pkgs[dir] = pkg
+      names = append(names, dir)
+    }
+    pkg.Statements += f.Statements
+    pkg.Covered += f.Covered
+  }
+  sort.Strings(names)
+  result := make([]FileCoverage, 0, len(names))
+  for _, name := range names {
+    result = append(result, *pkgs[name])
+  }
+  return result
+}
+
+// Total sums coverage over every file in the report.
+func (r *CoverageReport) Total() FileCoverage {
+  total := FileCoverage{Name: "total"}
+  for _, f := range r.Files {
+    total.Statements += f.Statements
+    total.Covered += f.Covered
+  }
+  return total
+}
+
+// CoverageChange is how the percentage covered moved between two reports.
+type CoverageChange struct {
+  Total float64
+  // Change for each file and package name found in both reports
+  Names map[string]float64
+}
+
+// CoverageDelta returns the change in percentage covered from prev to cur, or
+// nil when either is missing.
+func CoverageDelta(prev, cur *CoverageReport) *CoverageChange {
+  if prev == nil || cur == nil {
+    return nil
+  }
+  delta := map[string]float64{}
+  before := map[string]float64{}
+  for _, f := range coverageEntries(prev) {
+    before[f.Name] = f.Percent()
+  }
+  for _, f := range coverageEntries(cur) {
+    if p, ok := before[f.Name]; ok {
+      delta[f.Name] = f.Percent() - p
+    }
+  }
+  return &CoverageChange{Total: cur.Total().Percent() - prev.Total().Percent(),
+    Names: delta}
+}
+
+// coverageEntries lists the files of r followed by its packages.
+func coverageEntries(r *CoverageReport) []FileCoverage {
+  entries := make([]FileCoverage, 0, len(r.Files))
+  entries = append(entries, r.Files...)
+  return append(entries, r.Packages()...)
+}
+
+// ParseCoverProfile reads a profile written by go test -coverprofile. Blocks
+// repeated across packages, as with -coverpkg, are only counted once.
+func ParseCoverProfile(data []byte) (*CoverageReport, error) {
+  scanner := bufio.NewScanner(bytes.NewReader(data))
+  if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), "mode:") {
+    return nil, fmt.Errorf("%w: missing mode line", ErrBadCoverage)
+  }
+  // Statement counts of each block, and whether any run covered it
+  type block struct {
+    statements int
+    covered bool
+  }
+  blocks := map[string]map[string]*block{}
+  for scanner.Scan() {
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" {
+      continue
+    }
+    // name.go:line.col,line.col statements count
+    colon := strings.LastIndex(line, ":")
+    fields := strings.Fields(line[colon + 1:])
+    if colon == -1 || len(fields) != 3 {
+      return nil, fmt.Errorf("%w: %s", ErrBadCoverage, line)
+    }
+    statements, err := strconv.Atoi(fields[1])
+    if err != nil {
+      return nil, fmt.Errorf("%w: %s", ErrBadCoverage, line)
+    }
+    count, err := strconv.Atoi(fields[2])
+    if err != nil {
+      return nil, fmt.Errorf("%w: %s", ErrBadCoverage, line)
+    }
+    name := line[:colon]
+    if blocks[name] == nil {
+      blocks[name] = map[string]*block{}
+    }
+    b, ok := blocks[name][fields[0]]
+    if !ok {
+      b = &block{statements: statements}
+      blocks[name][fields[0]] = b
+    }
+    b.covered = b.covered || count > 0
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+
+  report := new(CoverageReport)
+  for name, file_blocks := range blocks {
+    f := FileCoverage{Name: name}
+    for _, b := range file_blocks {
+      f.Statements += b.statements
+      if b.covered {
+        f.Covered += b.statements
+      }
+    }
+    report.Files = append(report.Files, f)
+  }
+  sortCoverage(report.Files)
+  return report, nil
+}
+
+// ParseLcov reads an lcov tracefile, counting lines. Files listed more than
+// once, one record per test, are merged.
+func ParseLcov(data []byte) (*CoverageReport, error) {
+  lines := map[string]map[int]bool{}
+  name := ""
+  for _, line := range strings.Split(string(data), "\n") {
+    line = strings.TrimSpace(line)
+    switch {
+    case strings.HasPrefix(line, "SF:"):
+      name = line[3:]
+      if lines[name] == nil {
+        lines[name] = map[int]bool{}
+      }
+    case strings.HasPrefix(line, "DA:"):
+      if name == "" {
+        return nil, fmt.Errorf("%w: %s outside of a record", ErrBadCoverage, line)
+      }
+      // DA:line,count[,checksum]
+      fields := strings.Split(line[3:], ",")
+      if len(fields) < 2 {
+        return nil, fmt.Errorf("%w: %s", ErrBadCoverage, line)
+      }
+      number, err := strconv.Atoi(fields[0])
+      if err != nil {
+        return nil, fmt.Errorf("%w: %s", ErrBadCoverage, line)
+      }
+      // Counts may be written as floats by some tools
+      count, err := strconv.ParseFloat(fields[1], 64)
+      if err != nil {
+        return nil, fmt.Errorf("%w: %s", ErrBadCoverage, line)
+      }
+      lines[name][number] = lines[name][number] || count > 0
+    case line == "end_of_record":
+      name = ""
+    }
+  }
+  if len(lines) == 0 {
+    return nil, fmt.Errorf("%w: no source files", ErrBadCoverage)
+  }
+
+  report := new(CoverageReport)
+  for name, file_lines := range lines {
+    f := FileCoverage{Name: name, Statements: len(file_lines)}
+    for _, covered := range file_lines {
+      if covered {
+        f.Covered++
+      }
+    }
+    report.Files = append(report.Files, f)
+  }
+  sortCoverage(report.Files)
+  return report, nil
+}
+
+func sortCoverage(files []FileCoverage) {
+  sort.Slice(files, func(i, j int) bool {
+    return files[i].Name < files[j].Name
+  })
+}
+
+// ReadCoverage reads a Go coverprofile or lcov file, relative to dir, if it
+// was written since the run started. An older file gives no report.
+func ReadCoverage(dir, name string, since time.Time) (*CoverageReport, error) {
+  if !filepath.IsAbs(name) {
+    name = filepath.Join(dir, name)
+  }
+  info, err := os.Stat(name)
+  if err != nil {
+    return nil, err
+  }
+  // Some filesystems only keep modification times to the second
+  if info.ModTime().Before(since.Truncate(time.Second)) {
+    return nil, nil
+  }
+  data, err := ioutil.ReadFile(name)
+  if err != nil {
+    return nil, err
+  }
+  if bytes.HasPrefix(data, []byte("mode:")) {
+    return ParseCoverProfile(data)
+  }
+  return ParseLcov(data)
+}
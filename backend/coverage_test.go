@@ -0,0 +1,114 @@
+package backend
+
+import (
+  "errors"
+  "io/ioutil"
+  "testing"
+  "time"
+)
+
+func TestParseCoverProfile(t *testing.T) {
+  data, err := ioutil.ReadFile("test_util/coverage/cover.out")
+  if err != nil {
+    t.Fatal(err)
+  }
+  report, err := ParseCoverProfile(data)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Log(report)
+  expected := []FileCoverage{
+    FileCoverage{"example.com/calc/add.go", 4, 4},
+    FileCoverage{"example.com/calc/cmd/main.go", 2, 0},
+    FileCoverage{"example.com/calc/div.go", 3, 0},
+  }
+  if len(report.Files) != len(expected) {
+    t.Fatal("Parsed", len(report.Files), "files, expected", len(expected))
+  }
+  for i, f := range expected {
+    if report.Files[i] != f {
+      t.Error("Expected", f, "got", report.Files[i])
+    }
+  }
+  pkgs := report.Packages()
+  if len(pkgs) != 2 || pkgs[0] != (FileCoverage{"example.com/calc", 7, 4}) {
+    t.Error("Packages", pkgs)
+  }
+  if total := report.Total(); total.Statements != 9 || total.Covered != 4 {
+    t.Error("Total", total)
+  }
+}
+
+func TestParseCoverProfileBad(t *testing.T) {
+  for _, data := range []string{"add.go:1.1,2.2 1 1\n",
+    "mode: set\nadd.go:1.1,2.2 one 1\n"} {
+    if _, err := ParseCoverProfile([]byte(data)); !errors.Is(err, ErrBadCoverage) {
+      t.Error("Expected ErrBadCoverage for", data, "got", err)
+    }
+  }
+}
+
+func TestParseLcov(t *testing.T) {
+  data, err := ioutil.ReadFile("test_util/coverage/lcov.info")
+  if err != nil {
+    t.Fatal(err)
+  }
+  report, err := ParseLcov(data)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Log(report)
+  if len(report.Files) != 2 ||
+    report.Files[0] != (FileCoverage{"src/calc.ts", 4, 3}) ||
+    report.Files[1] != (FileCoverage{"src/util/strings.ts", 2, 1}) {
+    t.Error("Files", report.Files)
+  }
+}
+
+func TestReadCoverage(t *testing.T) {
+  for _, name := range []string{"cover.out", "lcov.info"} {
+    report, err := ReadCoverage("test_util/coverage", name, time.Time{})
+    if err != nil || len(report.Files) == 0 {
+      t.Error("Reading", name, report, err)
+    }
+  }
+  report, err := ReadCoverage("test_util/coverage", "cover.out",
+    time.Now().Add(time.Hour))
+  if report != nil || err != nil {
+    t.Error("Expected no report from before the run, got", report, err)
+  }
+}
+
+func TestCoverageDelta(t *testing.T) {
+  prev := &CoverageReport{Files: []FileCoverage{
+    FileCoverage{"pkg/a.go", 10, 5},
+    FileCoverage{"pkg/b.go", 10, 10},
+  }}
+  cur := &CoverageReport{Files: []FileCoverage{
+    FileCoverage{"pkg/a.go", 10, 8},
+    FileCoverage{"pkg/c.go", 10, 0},
+  }}
+  change := CoverageDelta(prev, cur)
+  t.Log(change)
+  delta := change.Names
+  if delta["pkg/a.go"] != 30 {
+    t.Error("Expected a.go up 30, got", delta["pkg/a.go"])
+  }
+  if _, ok := delta["pkg/c.go"]; ok {
+    t.Error("Expected no delta for new file c.go")
+  }
+  if delta["pkg"] != 40 - 75 || change.Total != 40 - 75 {
+    t.Error("Expected pkg and total down 35, got", delta["pkg"], change.Total)
+  }
+  if CoverageDelta(nil, cur) != nil {
+    t.Error("Expected no change without a previous report")
+  }
+
+  // A package called total is kept apart from the summary
+  prev.Files = append(prev.Files, FileCoverage{"total/t.go", 10, 10})
+  cur.Files = append(cur.Files, FileCoverage{"total/t.go", 10, 10})
+  change = CoverageDelta(prev, cur)
+  if change.Names["total"] != 0 || change.Total == 0 {
+    t.Error("Expected package total unchanged, got", change)
+  }
+}
@@ -0,0 +1,95 @@
+package backend
+
+import (
+  "fmt"
+  "regexp"
+  "strings"
+)
+
+// DiagnosticDiff compares the diagnostics of one run with the run before.
+type DiagnosticDiff struct {
+  New []CompileLine
+  Present []CompileLine
+  Fixed []CompileLine
+  newKeys map[string]bool
+}
+
+// Numbers and line:col positions standing alone, not digits within names
+var fingerprintNumberReg = regexp.MustCompile(`\b\d+(:\d+)*\b`)
+
+// Fingerprint identifies a diagnostic across runs. It leaves out the line and
+// column, and any numbers or positions in the message, so that edits
+// elsewhere in the file do not make an existing diagnostic look new.
+func Fingerprint(cl CompileLine) string {
+  message := strings.SplitN(strings.TrimSpace(cl.Message), "\n", 2)[0]
+  message = fingerprintNumberReg.ReplaceAllString(message, "#")
+  return strings.Join([]string{cl.FileName, cl.Tool, cl.Code,
+    cl.Severity.String(), message}, "\x00")
+}
+
+func diffKey(cl CompileLine) string {
+  return fmt.Sprintf("%s\x00%d\x00%d", Fingerprint(cl), cl.Line, cl.Column)
+}
+
+// DiffDiagnostics classifies each diagnostic in cur as new or still present
+// from prev, and finds those in prev which have been fixed. Diagnostics with
+// the same fingerprint are paired with the nearest line.
+func DiffDiagnostics(prev, cur []CompileLine) DiagnosticDiff {
+  diff := DiagnosticDiff{New: make([]CompileLine, 0),
+    Present: make([]CompileLine, 0), Fixed: make([]CompileLine, 0),
+    newKeys: map[string]bool{}}
+
+  unmatched := map[string][]CompileLine{}
+  for _, cl := range prev {
+    fp := Fingerprint(cl)
+    unmatched[fp] = append(unmatched[fp], cl)
+  }
+
+  for _, cl := range cur {
+    fp := Fingerprint(cl)
+    candidates := unmatched[fp]
+    if len(candidates) == 0 {
+      diff.New = append(diff.New, cl)
+      diff.newKeys[diffKey(cl)] = true
+      continue
+    }
+    nearest := 0
+    for i, p := range candidates {
+      if absInt(p.Line - cl.Line) < absInt(candidates[nearest].Line - cl.Line) {
+        nearest = i
+      }
+    }
+    unmatched[fp] = append(candidates[:nearest], candidates[nearest + 1:]...)
+    diff.Present = append(diff.Present, cl)
+  }
+
+  // Keep fixed diagnostics in the order they were reported
+  for _, cl := range prev {
+    fp := Fingerprint(cl)
+    for i, p := range unmatched[fp] {
+      if p.Line == cl.Line && p.Column == cl.Column {
+        diff.Fixed = append(diff.Fixed, cl)
+        unmatched[fp] = append(unmatched[fp][:i], unmatched[fp][i + 1:]...)
+        break
+      }
+    }
+  }
+  return diff
+}
+
+// IsNew reports whether cl was one of the new diagnostics in the diff.
+func (d DiagnosticDiff) IsNew(cl CompileLine) bool {
+  return d.newKeys[diffKey(cl)]
+}
+
+func (d DiagnosticDiff) String() string {
+  return fmt.Sprintf("%d new, %d fixed, %d unchanged", len(d.New),
+    len(d.Fixed), len(d.Present))
+}
+
+func absInt(i int) int {
+  if i < 0 {
+    return -i
+  }
+  return i
+}
@@ -0,0 +1,83 @@
+package backend
+
+import (
+  "testing"
+)
+
+func TestDiffDiagnostics(t *testing.T) {
+  prev := []CompileLine{
+    CompileLine{FileName: "a.go", Line: 10, Message: "undefined: x"},
+    CompileLine{FileName: "a.go", Line: 20, Message: "undefined: x"},
+    CompileLine{FileName: "b.go", Line: 5, Message: "missing return"},
+    CompileLine{FileName: "c.go", Line: 7, Message: "too many arguments, have 3 want 2"},
+  }
+  // Three lines were added to the top of a.go, b.go was fixed
+  cur := []CompileLine{
+    CompileLine{FileName: "a.go", Line: 23, Message: "undefined: x"},
+    CompileLine{FileName: "a.go", Line: 13, Message: "undefined: x"},
+    CompileLine{FileName: "a.go", Line: 30, Message: "undefined: y"},
+    CompileLine{FileName: "c.go", Line: 7, Message: "too many arguments, have 4 want 2"},
+  }
+  diff := DiffDiagnostics(prev, cur)
+  t.Log(diff)
+  if len(diff.New) != 1 || diff.New[0].Message != "undefined: y" {
+    t.Error("New", diff.New)
+  }
+  if len(diff.Present) != 3 {
+    t.Error("Present", diff.Present)
+  }
+  if len(diff.Fixed) != 1 || diff.Fixed[0].FileName != "b.go" {
+    t.Error("Fixed", diff.Fixed)
+  }
+  if !diff.IsNew(cur[2]) || diff.IsNew(cur[0]) {
+    t.Error("IsNew did not match New")
+  }
+  if diff.String() != "1 new, 1 fixed, 3 unchanged" {
+    t.Error("Summary", diff.String())
+  }
+}
+
+func TestDiffDiagnosticsDuplicates(t *testing.T) {
+  prev := []CompileLine{
+    CompileLine{FileName: "a.go", Line: 10, Message: "undefined: x"},
+    CompileLine{FileName: "a.go", Line: 40, Message: "undefined: x"},
+  }
+  cur := []CompileLine{
+    CompileLine{FileName: "a.go", Line: 41, Message: "undefined: x"},
+  }
+  diff := DiffDiagnostics(prev, cur)
+  if len(diff.Fixed) != 1 || diff.Fixed[0].Line != 10 || len(diff.New) != 0 {
+    t.Error("Expected line 10 fixed, got", diff)
+  }
+}
+
+func TestFingerprint(t *testing.T) {
+  a := CompileLine{FileName: "a.c", Line: 1, Severity: SeverityWarning,
+    Message: "unused variable\n    1 | int x;"}
+  b := CompileLine{FileName: "a.c", Line: 9, Severity: SeverityWarning,
+    Message: "unused variable\n    9 | int x;"}
+  if Fingerprint(a) != Fingerprint(b) {
+    t.Error("Expected fingerprints to ignore line and continuation")
+  }
+  b.Severity = SeverityError
+  if Fingerprint(a) == Fingerprint(b) {
+    t.Error("Expected fingerprints to differ by severity")
+  }
+}
+
+func TestFingerprintIdentifiers(t *testing.T) {
+  a := CompileLine{FileName: "a.go", Line: 3, Message: "undefined: var1"}
+  b := CompileLine{FileName: "a.go", Line: 3, Message: "undefined: var2"}
+  if Fingerprint(a) == Fingerprint(b) {
+    t.Error("Messages differing in an identifier share a fingerprint")
+  }
+  moved := CompileLine{FileName: "a.go", Message: "x redeclared, see a.go:12:5"}
+  shifted := CompileLine{FileName: "a.go", Message: "x redeclared, see a.go:15:5"}
+  if Fingerprint(moved) != Fingerprint(shifted) {
+    t.Error("Positions in messages changed the fingerprint")
+  }
+  diff := DiffDiagnostics([]CompileLine{a}, []CompileLine{b})
+  if len(diff.New) != 1 || len(diff.Fixed) != 1 {
+    t.Error("Expected var2 new and var1 fixed, got", diff)
+  }
+}
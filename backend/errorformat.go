@@ -0,0 +1,330 @@
+package backend
+
+import (
+  "errors"
+  "fmt"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+var ErrBadErrorformat = errors.New("Invalid errorformat")
+
+// Kinds of line an errorformat entry can describe, from the %E, %W, %C etc.
+// prefixes.
+type efmKind int
+
+const (
+  efmSingle efmKind = iota
+  efmStart
+  efmContinue
+  efmEnd
+  efmIgnore
+)
+
+type efmEntry struct {
+  re *regexp.Regexp
+  kind efmKind
+  severity Severity
+  typed bool
+  // %+ includes the whole line as the message, %- leaves the message alone
+  whole, quiet bool
+}
+
+// efmParser implements a subset of Vim's 'errorformat', covering %f %l %c %v
+// %m %t %n %p %r %s and the %E %W %I %N %A %C %Z %G multi-line prefixes.
+type efmParser struct {
+  tool string
+  entries []efmEntry
+}
+
+func NewErrorformatParser(tool, efm string) (Parser, error) {
+  p := &efmParser{tool: tool}
+  for _, format := range splitErrorformat(efm) {
+    if format == "" {
+      continue
+    }
+    entry, err := compileEfmEntry(format)
+    if err != nil {
+      return nil, err
+    }
+    p.entries = append(p.entries, entry)
+  }
+  if len(p.entries) == 0 {
+    return nil, fmt.Errorf("%w: no formats in %q", ErrBadErrorformat, efm)
+  }
+  return p, nil
+}
+
+// splitErrorformat splits efm on commas, except those escaped as \,
+func splitErrorformat(efm string) []string {
+  formats := make([]string, 0)
+  current := ""
+  for i := 0; i < len(efm); i++ {
+    if efm[i] == '\\' && i + 1 < len(efm) && efm[i + 1] == ',' {
+      current += ","
+      i++
+    } else if efm[i] == ',' {
+      formats = append(formats, current)
+      current = ""
+    } else {
+      current += string(efm[i])
+    }
+  }
+  return append(formats, current)
+}
+
+func compileEfmEntry(format string) (efmEntry, error) {
+  entry := efmEntry{}
+  rest := format
+  // Prefixes are written %E, %+C, %-G and so on
+  flagged := len(rest) >= 3 && rest[0] == '%' &&
+    (rest[1] == '+' || rest[1] == '-')
+  if flagged {
+    entry.whole = rest[1] == '+'
+    entry.quiet = rest[1] == '-'
+    rest = "%" + rest[2:]
+  }
+  if len(rest) >= 2 && rest[0] == '%' {
+    prefix := true
+    switch rest[1] {
+    case 'E':
+      entry.kind, entry.severity, entry.typed = efmStart, SeverityError, true
+    case 'W':
+      entry.kind, entry.severity, entry.typed = efmStart, SeverityWarning, true
+    case 'I', 'N':
+      entry.kind, entry.severity, entry.typed = efmStart, SeverityNote, true
+    case 'A':
+      entry.kind = efmStart
+    case 'C':
+      entry.kind = efmContinue
+    case 'Z':
+      entry.kind = efmEnd
+    case 'G':
+      entry.kind = efmIgnore
+    default:
+      prefix = false
+    }
+    if prefix {
+      rest = rest[2:]
+    } else if flagged {
+      return entry, fmt.Errorf("%w: %s: unknown prefix", ErrBadErrorformat,
+        format)
+    }
+  }
+
+  pattern, err := efmToRegexp(rest)
+  if err != nil {
+    return entry, fmt.Errorf("%w: %s: %s", ErrBadErrorformat, format, err)
+  }
+  entry.re, err = regexp.Compile(pattern)
+  if err != nil {
+    return entry, fmt.Errorf("%w: %s: %s", ErrBadErrorformat, format, err)
+  }
+  return entry, nil
+}
+
+var efmItems = map[byte]string{
+  'f': `(?P<file>.+?)`,
+  'l': `(?P<line>\d+)`,
+  'c': `(?P<col>\d+)`,
+  'v': `(?P<col>\d+)`,
+  'm': `(?P<message>.+)`,
+  't': `(?P<severity>.)`,
+  'n': `(?P<code>\d+)`,
+  'p': `(?P<pointer>[-\t .]*)`,
+  'r': `(?:.*)`,
+  's': `(?:.+?)`,
+  '.': `.`,
+  '#': `*`,
+  '^': `\^`,
+  '$': `\$`,
+  '~': `~`,
+  '%': `%`,
+}
+
+// efmToRegexp converts a single errorformat into an anchored regexp.
+func efmToRegexp(format string) (string, error) {
+  var b strings.Builder
+  b.WriteString("^")
+  seen := map[byte]bool{}
+  for i := 0; i < len(format); i++ {
+    ch := format[i]
+    if ch == '\\' && i + 1 < len(format) {
+      i++
+      b.WriteString(regexp.QuoteMeta(string(format[i])))
+      continue
+    }
+    if ch != '%' {
+      b.WriteString(regexp.QuoteMeta(string(ch)))
+      continue
+    }
+    i++
+    if i == len(format) {
+      return "", errors.New("trailing %")
+    }
+    item := format[i]
+    switch {
+    case item == '\\' && i + 1 < len(format):
+      i++
+      b.WriteString(`\` + string(format[i]))
+    case item == '*':
+      // %*[...] and %*\x repeat a class any number of times
+      class, n, err := efmClass(format[i + 1:])
+      if err != nil {
+        return "", err
+      }
+      b.WriteString(class + "*")
+      i += n
+    case item == '[':
+      class, n, err := efmClass(format[i:])
+      if err != nil {
+        return "", err
+      }
+      b.WriteString(class)
+      i += n - 1
+    default:
+      re, ok := efmItems[item]
+      if !ok {
+        return "", fmt.Errorf("unsupported item %%%c", item)
+      }
+      // Named groups may only appear once, %c and %v share a name
+      if item == 'v' {
+        item = 'c'
+      }
+      if seen[item] && strings.HasPrefix(re, "(?P") {
+        return "", fmt.Errorf("repeated item %%%c", item)
+      }
+      seen[item] = true
+      b.WriteString(re)
+    }
+  }
+  b.WriteString("$")
+  return b.String(), nil
+}
+
+// efmClass reads a [...] or \x character class from the start of s, returning
+// it along with the number of bytes consumed.
+func efmClass(s string) (string, int, error) {
+  if strings.HasPrefix(s, "[") {
+    end := strings.Index(s[1:], "]")
+    // A ] straight after [ or [^ is part of the class
+    if end == 0 || (end == 1 && s[1] == '^') {
+      next := strings.Index(s[end + 2:], "]")
+      if next == -1 {
+        return "", 0, errors.New("unterminated [")
+      }
+      end = end + 1 + next
+    }
+    if end == -1 {
+      return "", 0, errors.New("unterminated [")
+    }
+    return s[:end + 2], end + 2, nil
+  }
+  if strings.HasPrefix(s, `\`) && len(s) > 1 {
+    return s[:2], 2, nil
+  }
+  return "", 0, errors.New("%* must be followed by a class")
+}
+
+func (p *efmParser) Parse(output string) []CompileLine {
+  return scanAll(p.NewScanner(), output)
+}
+
+func (p *efmParser) NewScanner() LineScanner {
+  return &efmScanner{p: p}
+}
+
+type efmScanner struct {
+  p *efmParser
+  // Multi-line diagnostic being built
+  open *CompileLine
+}
+
+func (s *efmScanner) Line(line string) []CompileLine {
+  line = strings.TrimRight(line, "\r")
+  for _, entry := range s.p.entries {
+    match := entry.re.FindStringSubmatch(line)
+    if match == nil {
+      continue
+    }
+    if entry.kind == efmContinue || entry.kind == efmEnd {
+      if s.open == nil {
+        continue
+      }
+      s.p.fill(s.open, entry, match, line)
+      if entry.kind == efmEnd {
+        return s.Flush()
+      }
+      return nil
+    }
+    if entry.kind == efmIgnore {
+      return nil
+    }
+    done := s.Flush()
+    cl := CompileLine{Tool: s.p.tool}
+    s.p.fill(&cl, entry, match, line)
+    if entry.kind == efmStart {
+      s.open = &cl
+      return done
+    }
+    return append(done, efmLocated(cl)...)
+  }
+  return nil
+}
+
+func (s *efmScanner) Flush() []CompileLine {
+  if s.open == nil {
+    return nil
+  }
+  cl := *s.open
+  s.open = nil
+  return efmLocated(cl)
+}
+
+// Entries which never found a location are not useful diagnostics
+func efmLocated(cl CompileLine) []CompileLine {
+  if cl.FileName == "" {
+    return nil
+  }
+  return []CompileLine{cl}
+}
+
+// fill copies the items matched by entry into cl, appending to any message
+// already there.
+func (p *efmParser) fill(cl *CompileLine, entry efmEntry, match []string,
+  line string) {
+  if entry.typed {
+    cl.Severity = entry.severity
+  }
+  message := ""
+  for i, name := range entry.re.SubexpNames() {
+    switch name {
+    case "file":
+      cl.FileName = match[i]
+    case "line":
+      cl.Line, _ = strconv.Atoi(match[i])
+    case "col":
+      cl.Column, _ = strconv.Atoi(match[i])
+    case "pointer":
+      cl.Column = len(match[i]) + 1
+    case "severity":
+      cl.Severity = toolSeverity(match[i])
+    case "code":
+      cl.Code = match[i]
+    case "message":
+      message = match[i]
+    }
+  }
+  if entry.whole {
+    message = line
+  }
+  if message == "" || entry.quiet {
+    return
+  }
+  if cl.Message == "" {
+    cl.Message = message
+  } else {
+    cl.Message += "\n" + message
+  }
+}
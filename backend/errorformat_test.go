@@ -0,0 +1,84 @@
+package backend
+
+import (
+  "testing"
+  "errors"
+)
+
+func TestErrorformatSingle(t *testing.T) {
+  p, err := NewErrorformatParser("go", `%f:%l:%c: %m,%f:%l: %m,%-G#%.%#`)
+  if err != nil {
+    t.Fatal(err)
+  }
+  cls := p.Parse(pretend_out)
+  t.Log(cls)
+  if len(cls) != 7 {
+    t.Fatal("Parsed", len(cls), "lines, expected 7")
+  }
+  if cls[0].FileName != "src/github.com/MikeKneeB/coco/backend/parsers.go" ||
+    cls[0].Line != 28 || cls[0].Column != 21 ||
+    cls[0].Message != "undefined: output_split" || cls[0].Tool != "go" {
+    t.Error("First line", cls[0])
+  }
+}
+
+func TestErrorformatType(t *testing.T) {
+  p, err := NewErrorformatParser("gcc", `%f:%l:%c: %trror: %m,%f:%l:%c: %tarning: %m`)
+  if err != nil {
+    t.Fatal(err)
+  }
+  cls := p.Parse("a.c:1:2: error: one\nb.c:3:4: warning: two\n")
+  t.Log(cls)
+  if len(cls) != 2 || cls[0].Severity != SeverityError ||
+    cls[1].Severity != SeverityWarning || cls[1].Message != "two" {
+    t.Error("Parsed", cls)
+  }
+}
+
+func TestErrorformatMultiline(t *testing.T) {
+  efm := `%A  File "%f"\, line %l\,%m,%C    %.%#,%+Z%.%#Error: %.%#`
+  p, err := NewErrorformatParser("python", efm)
+  if err != nil {
+    t.Fatal(err)
+  }
+  cls := p.Parse(python_out)
+  t.Log(cls)
+  if len(cls) != 2 {
+    t.Fatal("Parsed", len(cls), "lines, expected 2")
+  }
+  if cls[1].FileName != "tool.py" || cls[1].Line != 6 ||
+    cls[1].Message != " in main\nValueError: bad" {
+    t.Error("Second line", cls[1])
+  }
+}
+
+func TestErrorformatPrefixes(t *testing.T) {
+  efm := `%E%f(%l): %m,%W%f(%l) warn: %m,%C  %m,%Z%*[-] %n`
+  p, err := NewErrorformatParser("tool", efm)
+  if err != nil {
+    t.Fatal(err)
+  }
+  out := "x.y(4) warn: careful\n  more detail\n--- 12\nz.y(9): broken\n"
+  cls := p.Parse(out)
+  t.Log(cls)
+  if len(cls) != 2 {
+    t.Fatal("Parsed", len(cls), "lines, expected 2")
+  }
+  if cls[0].Severity != SeverityWarning || cls[0].Code != "12" ||
+    cls[0].Message != "careful\nmore detail" {
+    t.Error("First line", cls[0])
+  }
+  if cls[1].Severity != SeverityError || cls[1].Line != 9 {
+    t.Error("Second line", cls[1])
+  }
+}
+
+func TestErrorformatBad(t *testing.T) {
+  for _, efm := range []string{"", "%f:%l:%q", "%f:%l:%f", "%*[abc", "%f%"} {
+    _, err := NewErrorformatParser("bad", efm)
+    t.Log(err)
+    if !errors.Is(err, ErrBadErrorformat) {
+      t.Error("Expected bad errorformat for", efm, "got", err)
+    }
+  }
+}
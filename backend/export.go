@@ -0,0 +1,70 @@
+package backend
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+)
+
+var ErrUnknownExport = errors.New("Unknown export format")
+
+// Exports named - are written here instead of to a file
+var exportStdout io.Writer = os.Stdout
+
+// ExportFunction writes diagnostics to w in some file format.
+type ExportFunction func(w io.Writer, cls []CompileLine) error
+
+var exporters = map[string]ExportFunction{
+  "sarif": WriteSARIF,
+  "quickfix": WriteQuickfix,
+  "emacs": WriteEmacs,
+  "github": WriteGitHub,
+  "checkstyle": WriteCheckstyle,
+}
+
+// exportNames returns the names of the export formats, sorted.
+func exportNames() []string {
+  names := make([]string, 0, len(exporters))
+  for name := range exporters {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// Export writes cls to the file name in format, or to stdout when name is -.
+// The file is replaced in one step, so that anything watching it never sees a
+// partial export.
+func Export(format, name string, cls []CompileLine) error {
+  export, ok := exporters[format]
+  if !ok {
+    return fmt.Errorf("%w: %s", ErrUnknownExport, format)
+  }
+  if name == "-" {
+    return export(exportStdout, cls)
+  }
+  if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+    return err
+  }
+  f, err := ioutil.TempFile(filepath.Dir(name), "." + filepath.Base(name))
+  if err != nil {
+    return err
+  }
+  err = export(f, cls)
+  if err == nil {
+    // TempFile makes files only the owner can read, exports are for sharing
+    err = f.Chmod(0644)
+  }
+  if close_err := f.Close(); err == nil {
+    err = close_err
+  }
+  if err != nil {
+    os.Remove(f.Name())
+    return err
+  }
+  return os.Rename(f.Name(), name)
+}
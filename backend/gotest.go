@@ -0,0 +1,239 @@
+package backend
+
+import (
+  "encoding/json"
+  "errors"
+  "strings"
+  "time"
+)
+
+var ErrNotTestJSON = errors.New("Output contains no go test -json events")
+
+type TestStatus int
+
+const (
+  TestRunning TestStatus = iota
+  TestPass
+  TestFail
+  TestSkip
+)
+
+func (s TestStatus) String() string {
+  switch s {
+  case TestPass:
+    return "PASS"
+  case TestFail:
+    return "FAIL"
+  case TestSkip:
+    return "SKIP"
+  default:
+    return "RUN"
+  }
+}
+
+type TestResult struct {
+  Name string
+  Status TestStatus
+  Elapsed time.Duration
+  Output string
+  Subtests []*TestResult
+}
+
+type PackageResult struct {
+  Name string
+  Status TestStatus
+  Elapsed time.Duration
+  // Output not belonging to any one test, e.g. the final ok/FAIL line
+  Output string
+  Tests []*TestResult
+}
+
+// Count returns the number of tests and subtests in the package with status.
+func (p *PackageResult) Count(status TestStatus) int {
+  return countTests(p.Tests, status)
+}
+
+func countTests(tests []*TestResult, status TestStatus) int {
+  n := 0
+  for _, t := range tests {
+    if t.Status == status {
+      n++
+    }
+    n += countTests(t.Subtests, status)
+  }
+  return n
+}
+
+type TestReport struct {
+  Packages []*PackageResult
+  // Lines which were not test2json events, such as compiler errors
+  Other string
+}
+
+// Failed reports whether any package in the report failed.
+func (r *TestReport) Failed() bool {
+  for _, p := range r.Packages {
+    if p.Status == TestFail {
+      return true
+    }
+  }
+  return false
+}
+
+// A single event as written by go test -json, see go doc test2json.
+type goTestEvent struct {
+  Time time.Time
+  Action string
+  Package string
+  ImportPath string
+  Test string
+  Elapsed float64
+  Output string
+}
+
+// ParseGoTestJSON builds a package and test tree from go test -json output.
+func ParseGoTestJSON(output string) (*TestReport, error) {
+  report := new(TestReport)
+  packages := map[string]*PackageResult{}
+  tests := map[string]*TestResult{}
+  events := 0
+
+  for _, line := range strings.Split(output, "\n") {
+    ev := goTestEvent{}
+    if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &ev) != nil {
+      if strings.TrimSpace(line) != "" {
+        report.Other += line + "\n"
+      }
+      continue
+    }
+    events++
+    // Build events name the package by ImportPath instead
+    if ev.Action == "build-output" || ev.Action == "build-fail" {
+      if ev.Action == "build-output" {
+        report.Other += ev.Output
+      }
+      continue
+    }
+
+    pkg, ok := packages[ev.Package]
+    if !ok {
+      pkg = &PackageResult{Name: ev.Package}
+      packages[ev.Package] = pkg
+      report.Packages = append(report.Packages, pkg)
+    }
+
+    if ev.Test == "" {
+      if ev.Action == "output" {
+        pkg.Output += ev.Output
+      } else if status, ok := goTestStatus(ev.Action); ok {
+        pkg.Status = status
+        pkg.Elapsed = goTestElapsed(ev.Elapsed)
+      }
+      continue
+    }
+
+    key := ev.Package + " " + ev.Test
+    test, ok := tests[key]
+    if !ok {
+      test = &TestResult{Name: ev.Test}
+      tests[key] = test
+      // Subtests are named Parent/sub, attach them under the parent
+      parent, found := (*TestResult)(nil), false
+      if i := strings.LastIndex(ev.Test, "/"); i != -1 {
+        parent, found = tests[ev.Package + " " + ev.Test[:i]]
+      }
+      if found {
+        parent.Subtests = append(parent.Subtests, test)
+      } else {
+        pkg.Tests = append(pkg.Tests, test)
+      }
+    }
+    if ev.Action == "output" {
+      test.Output += ev.Output
+    } else if status, ok := goTestStatus(ev.Action); ok {
+      test.Status = status
+      test.Elapsed = goTestElapsed(ev.Elapsed)
+    }
+  }
+
+  if events == 0 {
+    return nil, ErrNotTestJSON
+  }
+  return report, nil
+}
+
+func goTestStatus(action string) (TestStatus, bool) {
+  switch action {
+  case "pass":
+    return TestPass, true
+  case "fail":
+    return TestFail, true
+  case "skip":
+    return TestSkip, true
+  case "run", "start":
+    return TestRunning, true
+  }
+  return TestRunning, false
+}
+
+func goTestElapsed(seconds float64) time.Duration {
+  return time.Duration(seconds * float64(time.Second))
+}
+
+// IsGoTestJSON reports whether c runs go test with -json output.
+func IsGoTestJSON(c CommandDef) bool {
+  return isGoJSON(c, "test")
+}
+
+// IsGoVetJSON reports whether c runs go vet with -json output.
+func IsGoVetJSON(c CommandDef) bool {
+  return isGoJSON(c, "vet")
+}
+
+func isGoJSON(c CommandDef, sub string) bool {
+  if c.Name != "go" && !strings.HasSuffix(c.Name, "/go") {
+    return false
+  }
+  found, json := false, false
+  for _, v := range c.Args {
+    found = found || v == sub
+    json = json || v == "-json" || v == "--json"
+  }
+  return found && json
+}
+
+// goTestParser reports the file and line of failures printed by failing
+// tests, along with any build errors.
+type goTestParser struct {}
+
+func (p goTestParser) Parse(output string) []CompileLine {
+  report, err := ParseGoTestJSON(output)
+  if err != nil {
+    return parsers["go"].Parse(output)
+  }
+  cls := parsers["go"].Parse(report.Other)
+  for _, pkg := range report.Packages {
+    cls = append(cls, failedTestLines(pkg.Tests)...)
+  }
+  return cls
+}
+
+func failedTestLines(tests []*TestResult) []CompileLine {
+  cls := make([]CompileLine, 0)
+  for _, t := range tests {
+    if t.Status != TestFail {
+      continue
+    }
+    // Failures are reported by the innermost failing subtest
+    if sub := failedTestLines(t.Subtests); len(sub) != 0 {
+      cls = append(cls, sub...)
+      continue
+    }
+    for _, cl := range parsers["go"].Parse(t.Output) {
+      cl.Tool = "go test"
+      cl.Message = t.Name + ": " + cl.Message
+      cls = append(cls, cl)
+    }
+  }
+  return cls
+}
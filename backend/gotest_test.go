@@ -0,0 +1,98 @@
+package backend
+
+import (
+  "testing"
+  "time"
+)
+
+var go_test_out string = `{"Action":"start","Package":"example.com/calc"}
+{"Action":"run","Package":"example.com/calc","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"--- PASS: TestAdd (0.00s)\n"}
+{"Action":"pass","Package":"example.com/calc","Test":"TestAdd","Elapsed":0}
+{"Action":"run","Package":"example.com/calc","Test":"TestDiv"}
+{"Action":"run","Package":"example.com/calc","Test":"TestDiv/zero"}
+{"Action":"output","Package":"example.com/calc","Test":"TestDiv/zero","Output":"    calc_test.go:21: expected error dividing by zero\n"}
+{"Action":"fail","Package":"example.com/calc","Test":"TestDiv/zero","Elapsed":0.01}
+{"Action":"run","Package":"example.com/calc","Test":"TestDiv/one"}
+{"Action":"pass","Package":"example.com/calc","Test":"TestDiv/one","Elapsed":0}
+{"Action":"fail","Package":"example.com/calc","Test":"TestDiv","Elapsed":0.02}
+{"Action":"run","Package":"example.com/calc","Test":"TestSlow"}
+{"Action":"skip","Package":"example.com/calc","Test":"TestSlow","Elapsed":0}
+{"Action":"output","Package":"example.com/calc","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/calc","Elapsed":0.5}
+{"Action":"start","Package":"example.com/util"}
+{"Action":"output","Package":"example.com/util","Output":"?   \texample.com/util\t[no test files]\n"}
+{"Action":"skip","Package":"example.com/util","Elapsed":0}
+`
+
+func TestParseGoTestJSON(t *testing.T) {
+  report, err := ParseGoTestJSON(go_test_out)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(report.Packages) != 2 || !report.Failed() {
+    t.Fatal("Report", report.Packages)
+  }
+
+  calc := report.Packages[0]
+  if calc.Name != "example.com/calc" || calc.Status != TestFail ||
+    calc.Elapsed != 500 * time.Millisecond || len(calc.Tests) != 3 {
+    t.Fatal("Package", calc)
+  }
+  div := calc.Tests[1]
+  if div.Name != "TestDiv" || div.Status != TestFail || len(div.Subtests) != 2 {
+    t.Fatal("Test", div)
+  }
+  if div.Subtests[0].Output != "    calc_test.go:21: expected error dividing by zero\n" {
+    t.Error("Subtest output", div.Subtests[0].Output)
+  }
+  if calc.Count(TestPass) != 2 || calc.Count(TestFail) != 2 ||
+    calc.Count(TestSkip) != 1 {
+    t.Error("Counts", calc.Count(TestPass), calc.Count(TestFail),
+      calc.Count(TestSkip))
+  }
+
+  if report.Packages[1].Status != TestSkip {
+    t.Error("Expected util package skipped")
+  }
+}
+
+func TestParseGoTestJSONBuildFail(t *testing.T) {
+  out := "# example.com/calc\ncalc.go:3:2: undefined: x\n" +
+    `{"Action":"fail","Package":"example.com/calc","Elapsed":0}` + "\n"
+  report, err := ParseGoTestJSON(out)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if report.Other != "# example.com/calc\ncalc.go:3:2: undefined: x\n" {
+    t.Error("Other output", report.Other)
+  }
+
+  _, err = ParseGoTestJSON("plain text\n")
+  if err != ErrNotTestJSON {
+    t.Error("Expected not test json, got", err)
+  }
+}
+
+func TestGoTestParser(t *testing.T) {
+  p, _ := GetParser("gotest")
+  cls := p.Parse(go_test_out)
+  t.Log(cls)
+  if len(cls) != 1 || cls[0].FileName != "calc_test.go" || cls[0].Line != 21 ||
+    cls[0].Message != "TestDiv/zero: expected error dividing by zero" {
+    t.Error("Parsed", cls)
+  }
+}
+
+func TestIsGoTestJSON(t *testing.T) {
+  if !IsGoTestJSON(CommandDef{Name: "go", Args: []string{"test", "-json", "./..."}}) {
+    t.Error("Expected go test -json")
+  }
+  if IsGoTestJSON(CommandDef{Name: "go", Args: []string{"test", "./..."}}) {
+    t.Error("Expected plain go test")
+  }
+  if IsGoTestJSON(CommandDef{Name: "gotest", Args: []string{"test", "-json"}}) {
+    t.Error("Expected other command")
+  }
+}
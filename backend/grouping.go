@@ -0,0 +1,93 @@
+package backend
+
+import (
+  "regexp"
+  "strconv"
+)
+
+// gccParser groups gcc and clang output into one diagnostic per error or
+// warning. Include stacks and function or template context printed before a
+// diagnostic, and notes printed after it, are attached as Related entries.
+type gccParser struct {
+  diag *regexParser
+}
+
+var gccIncludeReg = regexp.MustCompile(
+  `^(?:In file included|\s+) from (.+?):(\d+)(?::(\d+))?[:,]$`)
+var gccContextReg = regexp.MustCompile(
+  `^([^\s:][^:]*): (In (?:function|member function|constructor|destructor|instantiation of|substitution of|lambda function|static member function) .*|At global scope|At top level):$`)
+var gccRequiredReg = regexp.MustCompile(
+  `^([^\s:][^:]*):(\d+):(?:(\d+):)?\s+(required (?:from|by) .*|recursively required .*)$`)
+
+func newGccParser() gccParser {
+  return gccParser{newRegexParser("gcc", "",
+    `^(?P<file>[^\s:][^:]*):(?P<line>\d+):(?:(?P<col>\d+):)? (?:fatal )?(?P<severity>error|warning|note): (?P<message>.*?)(?: \[(?P<code>-W[^\]]+)\])?$`)}
+}
+
+func (p gccParser) Parse(output string) []CompileLine {
+  return scanAll(p.NewScanner(), output)
+}
+
+func (p gccParser) NewScanner() LineScanner {
+  return &gccScanner{p: p}
+}
+
+type gccScanner struct {
+  p gccParser
+  // Diagnostic which may still have notes to come
+  current *CompileLine
+  // Context seen since the last diagnostic, belonging to the next one
+  pending []CompileLine
+}
+
+func (s *gccScanner) Line(line string) []CompileLine {
+  if match := gccIncludeReg.FindStringSubmatch(line); match != nil {
+    s.pending = append(s.pending, gccContext(match[1], match[2], match[3],
+      "included from here"))
+  } else if match := gccContextReg.FindStringSubmatch(line); match != nil {
+    s.pending = append(s.pending, gccContext(match[1], "", "", match[2]))
+  } else if match := gccRequiredReg.FindStringSubmatch(line); match != nil {
+    s.pending = append(s.pending, gccContext(match[1], match[2], match[3],
+      match[4]))
+  } else if cl, ok := s.p.diag.match(line); ok {
+    if cl.Severity == SeverityNote && s.current != nil {
+      s.current.Related = append(s.current.Related, s.pending...)
+      s.current.Related = append(s.current.Related, cl)
+      s.pending = nil
+      return nil
+    }
+    done := s.Flush()
+    if len(s.pending) != 0 {
+      cl.Related = s.pending
+    }
+    s.pending = nil
+    s.current = &cl
+    return done
+  } else {
+    return nil
+  }
+  // Context lines start the next diagnostic, so the current one is complete
+  if s.current == nil {
+    return nil
+  }
+  cl := *s.current
+  s.current = nil
+  return []CompileLine{cl}
+}
+
+func (s *gccScanner) Flush() []CompileLine {
+  if s.current == nil {
+    return nil
+  }
+  cl := *s.current
+  s.current = nil
+  return []CompileLine{cl}
+}
+
+func gccContext(file, line, col, message string) CompileLine {
+  cl := CompileLine{FileName: file, Severity: SeverityNote, Tool: "gcc",
+    Message: message}
+  cl.Line, _ = strconv.Atoi(line)
+  cl.Column, _ = strconv.Atoi(col)
+  return cl
+}
@@ -0,0 +1,56 @@
+package backend
+
+import (
+  "testing"
+)
+
+var gcc_grouped_out string = `In file included from src/main.cpp:2:
+include/util.h: In instantiation of 'void show(T) [with T = Widget]':
+src/main.cpp:8:7:   required from here
+include/util.h:5:13: error: no match for 'operator<<' (operand types are 'std::ostream' and 'Widget')
+include/util.h:5:13: note: candidate: 'std::ostream& operator<<(std::ostream&, const Gadget&)'
+    5 |   std::cout << t;
+      |             ^
+src/main.cpp:12:3: warning: unused variable 'w' [-Wunused-variable]
+In file included from include/a.h:1,
+                 from src/other.cpp:1:
+include/b.h:3:1: error: 'x' does not name a type
+`
+
+func TestGccGrouping(t *testing.T) {
+  p, _ := GetParser("gcc")
+  cls := p.Parse(gcc_grouped_out)
+  t.Log(cls)
+  if len(cls) != 3 {
+    t.Fatal("Parsed", len(cls), "diagnostics, expected 3")
+  }
+
+  first := cls[0]
+  if first.FileName != "include/util.h" || first.Line != 5 ||
+    len(first.Related) != 4 {
+    t.Fatal("First diagnostic", first)
+  }
+  expected := []string{"included from here",
+    "In instantiation of 'void show(T) [with T = Widget]'",
+    "required from here",
+    "candidate: 'std::ostream& operator<<(std::ostream&, const Gadget&)'"}
+  for i, v := range expected {
+    if first.Related[i].Message != v || first.Related[i].Severity != SeverityNote {
+      t.Error("Related", i, first.Related[i], "expected", v)
+    }
+  }
+  if first.Related[2].FileName != "src/main.cpp" || first.Related[2].Line != 8 ||
+    first.Related[2].Column != 7 {
+    t.Error("Required from", first.Related[2])
+  }
+
+  if len(cls[1].Related) != 0 || cls[1].Code != "-Wunused-variable" {
+    t.Error("Second diagnostic", cls[1])
+  }
+
+  third := cls[2]
+  if len(third.Related) != 2 || third.Related[0].FileName != "include/a.h" ||
+    third.Related[1].FileName != "src/other.cpp" {
+    t.Error("Include stack", third.Related)
+  }
+}
@@ -0,0 +1,156 @@
+package backend
+
+import (
+  "encoding/xml"
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "regexp"
+  "strconv"
+  "strings"
+  "time"
+)
+
+var ErrBadReport = errors.New("Skipped unreadable report")
+
+type junitFailure struct {
+  Message string `xml:"message,attr"`
+  Type string `xml:"type,attr"`
+  Text string `xml:",chardata"`
+}
+
+type junitCase struct {
+  Name string `xml:"name,attr"`
+  ClassName string `xml:"classname,attr"`
+  File string `xml:"file,attr"`
+  Line int `xml:"line,attr"`
+  Failures []junitFailure `xml:"failure"`
+  Errors []junitFailure `xml:"error"`
+}
+
+// junitSuite covers both <testsuites> and <testsuite>, which may be nested.
+type junitSuite struct {
+  Name string `xml:"name,attr"`
+  File string `xml:"file,attr"`
+  Suites []junitSuite `xml:"testsuite"`
+  Cases []junitCase `xml:"testcase"`
+}
+
+// Locations looked for in stack traces when a testcase has no file attribute,
+// tried in order.
+var junitTraceRegs = []*regexp.Regexp{
+  regexp.MustCompile(`\bat .*?\(([^()\s:]+):(\d+)(?::\d+)?\)`),
+  regexp.MustCompile(`File "([^"]+)", line (\d+)`),
+  regexp.MustCompile(`(?m)^\s*([^\s:()]+\.\w+):(\d+)`),
+}
+
+// ParseJUnit turns the failed and errored testcases of a JUnit XML report
+// into diagnostics.
+func ParseJUnit(data []byte) ([]CompileLine, error) {
+  suite := junitSuite{}
+  err := xml.Unmarshal(data, &suite)
+  if err != nil {
+    return nil, err
+  }
+  return junitSuiteLines(suite, ""), nil
+}
+
+func junitSuiteLines(suite junitSuite, file string) []CompileLine {
+  if suite.File != "" {
+    file = suite.File
+  }
+  cls := make([]CompileLine, 0)
+  for _, s := range suite.Suites {
+    cls = append(cls, junitSuiteLines(s, file)...)
+  }
+  for _, c := range suite.Cases {
+    for _, f := range append(c.Failures, c.Errors...) {
+      cls = append(cls, junitCaseLine(c, f, file))
+    }
+  }
+  return cls
+}
+
+func junitCaseLine(c junitCase, f junitFailure, file string) CompileLine {
+  name := c.Name
+  if c.ClassName != "" {
+    name = c.ClassName + "." + c.Name
+  }
+  message := strings.TrimSpace(f.Message)
+  if message == "" {
+    message = f.Type
+  }
+  cl := CompileLine{FileName: c.File, Line: c.Line, Tool: "junit",
+    Code: name, Message: name + ": " + message}
+  if trace := strings.TrimSpace(f.Text); trace != "" {
+    cl.Message += "\n" + trace
+  }
+  // Only trust a trace location for the file the testcase names, if any
+  if cl.Line == 0 {
+    trace_file, line := junitTraceLocation(f.Text)
+    if trace_file != "" && (cl.FileName == "" ||
+      filepath.Base(trace_file) == filepath.Base(cl.FileName)) {
+      cl.FileName, cl.Line = trace_file, line
+    }
+  }
+  if cl.FileName == "" {
+    cl.FileName = file
+  }
+  return cl
+}
+
+// junitTraceLocation finds the most relevant file and line in a stack trace,
+// the innermost frame. Python prints it last, everything else prints it first.
+func junitTraceLocation(trace string) (string, int) {
+  for i, re := range junitTraceRegs {
+    matches := re.FindAllStringSubmatch(trace, -1)
+    if len(matches) == 0 {
+      continue
+    }
+    match := matches[0]
+    if i == 1 {
+      match = matches[len(matches) - 1]
+    }
+    line, _ := strconv.Atoi(match[2])
+    return match[1], line
+  }
+  return "", 0
+}
+
+// ReadJUnitReports parses every report matching the globs, relative to dir,
+// which was written since the run started. It returns the failures from the
+// reports it could read, along with ErrBadReport for each it could not.
+func ReadJUnitReports(dir string, globs []string,
+  since time.Time) ([]CompileLine, error) {
+  cls := make([]CompileLine, 0)
+  bad := make([]error, 0)
+  // Some filesystems only keep modification times to the second
+  since = since.Truncate(time.Second)
+  for _, g := range globs {
+    if !filepath.IsAbs(g) {
+      g = filepath.Join(dir, g)
+    }
+    names, err := filepath.Glob(g)
+    if err != nil {
+      return nil, err
+    }
+    for _, name := range names {
+      // Reports left behind by earlier runs, e.g. of a deleted test
+      if info, err := os.Stat(name); err == nil && info.ModTime().Before(since) {
+        continue
+      }
+      data, err := ioutil.ReadFile(name)
+      if err == nil {
+        var report []CompileLine
+        report, err = ParseJUnit(data)
+        cls = append(cls, report...)
+      }
+      if err != nil {
+        bad = append(bad, fmt.Errorf("%w %s: %v", ErrBadReport, name, err))
+      }
+    }
+  }
+  return cls, errors.Join(bad...)
+}
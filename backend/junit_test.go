@@ -0,0 +1,102 @@
+package backend
+
+import (
+  "errors"
+  "testing"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "time"
+)
+
+func TestParseJUnitPytest(t *testing.T) {
+  data, err := ioutil.ReadFile("test_util/junit/pytest.xml")
+  if err != nil {
+    t.Fatal(err)
+  }
+  cls, err := ParseJUnit(data)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Log(cls)
+  if len(cls) != 1 {
+    t.Fatal("Parsed", len(cls), "failures, expected 1")
+  }
+  if cls[0].FileName != "tests/test_tool.py" || cls[0].Line != 9 ||
+    cls[0].Code != "tests.test_tool.test_parse" || cls[0].Tool != "junit" {
+    t.Error("Failure", cls[0])
+  }
+}
+
+func TestParseJUnitGradle(t *testing.T) {
+  data, err := ioutil.ReadFile("test_util/junit/gradle.xml")
+  if err != nil {
+    t.Fatal(err)
+  }
+  cls, err := ParseJUnit(data)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Log(cls)
+  if len(cls) != 1 {
+    t.Fatal("Parsed", len(cls), "failures, expected 1")
+  }
+  if cls[0].FileName != "Calc.java" || cls[0].Line != 12 ||
+    cls[0].Message[:47] != "com.example.CalcTest.divides: java.lang.Arithme" {
+    t.Error("Failure", cls[0])
+  }
+}
+
+func TestParseJUnitBad(t *testing.T) {
+  _, err := ParseJUnit([]byte("<testsuite><testcase"))
+  t.Log(err)
+  if err == nil {
+    t.Error("Expected error for truncated xml")
+  }
+}
+
+func TestReadJUnitReports(t *testing.T) {
+  cls, err := ReadJUnitReports("test_util", []string{"junit/*.xml"}, time.Time{})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(cls) != 2 {
+    t.Error("Read", len(cls), "failures, expected 2")
+  }
+
+  cls, err = ReadJUnitReports("test_util", []string{"nothing/*.xml"},
+    time.Time{})
+  if err != nil || len(cls) != 0 {
+    t.Error("Expected no failures from no reports", cls, err)
+  }
+}
+
+func TestReadJUnitReportsStaleAndBad(t *testing.T) {
+  dir := t.TempDir()
+  data, err := ioutil.ReadFile("test_util/junit/pytest.xml")
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, name := range []string{"old.xml", "new.xml"} {
+    if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+      t.Fatal(err)
+    }
+  }
+  err = ioutil.WriteFile(filepath.Join(dir, "half.xml"),
+    []byte("<testsuite><testcase"), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  started := time.Now().Add(-time.Minute)
+  old := started.Add(-time.Hour)
+  os.Chtimes(filepath.Join(dir, "old.xml"), old, old)
+
+  cls, err := ReadJUnitReports(dir, []string{"*.xml"}, started)
+  t.Log(err)
+  if len(cls) != 1 {
+    t.Error("Read", len(cls), "failures, expected 1 from the new report")
+  }
+  if !errors.Is(err, ErrBadReport) {
+    t.Error("Expected ErrBadReport for the half written report, got", err)
+  }
+}
@@ -0,0 +1,235 @@
+package backend
+
+import (
+  "encoding/json"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// TextEdit replaces the text between byte offsets Start and End of FileName
+// with NewText. When offsets are unknown End is zero and the whole of lines
+// Line to EndLine are replaced.
+type TextEdit struct {
+  FileName string
+  Start, End int
+  Line, EndLine int
+  NewText string
+}
+
+type SuggestedFix struct {
+  Message string
+  Edits []TextEdit
+}
+
+// jsonStart returns output from its first line beginning with {, linters
+// often print other text around their report.
+func jsonStart(output string) (string, bool) {
+  lines := strings.Split(output, "\n")
+  for i, line := range lines {
+    if strings.HasPrefix(line, "{") {
+      return strings.Join(lines[i:], "\n"), true
+    }
+  }
+  return "", false
+}
+
+// lintSeverity treats linter findings without a severity as warnings.
+func lintSeverity(s string) Severity {
+  if s == "" {
+    return SeverityWarning
+  }
+  return toolSeverity(s)
+}
+
+type golangciPosition struct {
+  Filename string
+  Offset int
+  Line int
+  Column int
+}
+
+type golangciIssue struct {
+  FromLinter string
+  Text string
+  Severity string
+  SourceLines []string
+  Replacement *struct {
+    NeedOnlyDelete bool
+    NewLines []string
+    Inline *struct {
+      StartCol int
+      Length int
+      NewString string
+    }
+  }
+  Pos golangciPosition
+}
+
+// golangciParser reads golangci-lint --out-format json reports.
+type golangciParser struct {}
+
+func (p golangciParser) Parse(output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  text, ok := jsonStart(output)
+  if !ok {
+    return cls
+  }
+  report := struct {
+    Issues []golangciIssue
+  }{}
+  if json.NewDecoder(strings.NewReader(text)).Decode(&report) != nil {
+    return cls
+  }
+  for _, issue := range report.Issues {
+    cl := CompileLine{FileName: issue.Pos.Filename, Line: issue.Pos.Line,
+      Column: issue.Pos.Column, Severity: lintSeverity(issue.Severity),
+      Code: issue.FromLinter, Tool: "golangci-lint", Message: issue.Text}
+    if r := issue.Replacement; r != nil {
+      edit := TextEdit{FileName: issue.Pos.Filename, Line: issue.Pos.Line,
+        EndLine: issue.Pos.Line + len(issue.SourceLines) - 1,
+        NewText: strings.Join(r.NewLines, "\n")}
+      if r.Inline != nil {
+        // Inline columns are 0 based within the issue's line
+        edit.Start = issue.Pos.Offset - (issue.Pos.Column - 1) + r.Inline.StartCol
+        edit.End = edit.Start + r.Inline.Length
+        edit.NewText = r.Inline.NewString
+      }
+      if edit.EndLine < edit.Line {
+        edit.EndLine = edit.Line
+      }
+      cl.Fixes = []SuggestedFix{SuggestedFix{Message: "Apply " +
+        issue.FromLinter + " fix", Edits: []TextEdit{edit}}}
+    }
+    cls = append(cls, cl)
+  }
+  return cls
+}
+
+type staticcheckLocation struct {
+  File string
+  Line int
+  Column int
+}
+
+type staticcheckIssue struct {
+  Code string
+  Severity string
+  Location staticcheckLocation
+  Message string
+  Related []struct {
+    Location staticcheckLocation
+    Message string
+  }
+}
+
+// staticcheckParser reads staticcheck -f json output, one issue per line.
+type staticcheckParser struct {}
+
+func (p staticcheckParser) Parse(output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  for _, line := range strings.Split(output, "\n") {
+    issue := staticcheckIssue{}
+    if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &issue) != nil {
+      continue
+    }
+    cl := CompileLine{FileName: issue.Location.File, Line: issue.Location.Line,
+      Column: issue.Location.Column, Severity: lintSeverity(issue.Severity),
+      Code: issue.Code, Tool: "staticcheck", Message: issue.Message}
+    for _, r := range issue.Related {
+      cl.Related = append(cl.Related, CompileLine{FileName: r.Location.File,
+        Line: r.Location.Line, Column: r.Location.Column,
+        Severity: SeverityNote, Tool: "staticcheck", Message: r.Message})
+    }
+    cls = append(cls, cl)
+  }
+  return cls
+}
+
+type vetDiagnostic struct {
+  Posn string
+  Message string
+  SuggestedFixes []struct {
+    Message string
+    Edits []struct {
+      Filename string
+      Start int
+      End int
+      New string
+    }
+  } `json:"suggested_fixes"`
+}
+
+// vetParser reads go vet -json output, a JSON object per package mapping each
+// analyzer to its diagnostics, with # package lines in between.
+type vetParser struct {}
+
+func (p vetParser) Parse(output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  lines := make([]string, 0)
+  for _, line := range strings.Split(output, "\n") {
+    if !strings.HasPrefix(line, "#") {
+      lines = append(lines, line)
+    }
+  }
+  text, ok := jsonStart(strings.Join(lines, "\n"))
+  if !ok {
+    return cls
+  }
+  dec := json.NewDecoder(strings.NewReader(text))
+  for {
+    pkgs := map[string]json.RawMessage{}
+    if dec.Decode(&pkgs) != nil {
+      break
+    }
+    for _, pkg := range sortedKeys(pkgs) {
+      analyzers := map[string]json.RawMessage{}
+      if json.Unmarshal(pkgs[pkg], &analyzers) != nil {
+        continue
+      }
+      for _, analyzer := range sortedKeys(analyzers) {
+        diags := []vetDiagnostic{}
+        // Analyzers which failed report {"error": ...} instead of a list
+        if json.Unmarshal(analyzers[analyzer], &diags) != nil {
+          continue
+        }
+        for _, d := range diags {
+          cls = append(cls, vetLine(analyzer, d))
+        }
+      }
+    }
+  }
+  return cls
+}
+
+func vetLine(analyzer string, d vetDiagnostic) CompileLine {
+  cl := CompileLine{Severity: SeverityWarning, Code: analyzer, Tool: "go vet",
+    Message: d.Message}
+  // Positions are file:line:col, where file may itself contain colons
+  parts := strings.Split(d.Posn, ":")
+  if len(parts) >= 3 {
+    cl.FileName = strings.Join(parts[:len(parts) - 2], ":")
+    cl.Line, _ = strconv.Atoi(parts[len(parts) - 2])
+    cl.Column, _ = strconv.Atoi(parts[len(parts) - 1])
+  } else {
+    cl.FileName = d.Posn
+  }
+  for _, f := range d.SuggestedFixes {
+    fix := SuggestedFix{Message: f.Message}
+    for _, e := range f.Edits {
+      fix.Edits = append(fix.Edits, TextEdit{FileName: e.Filename,
+        Start: e.Start, End: e.End, NewText: e.New})
+    }
+    cl.Fixes = append(cl.Fixes, fix)
+  }
+  return cl
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+  keys := make([]string, 0, len(m))
+  for k := range m {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+  return keys
+}
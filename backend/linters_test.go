@@ -0,0 +1,115 @@
+package backend
+
+import (
+  "testing"
+)
+
+var golangci_out string = `{"Issues":[{"FromLinter":"errcheck","Text":"Error return value of ` + "`f.Close`" + ` is not checked","Severity":"","SourceLines":["\tf.Close()"],"Replacement":null,"Pos":{"Filename":"store/store.go","Offset":310,"Line":22,"Column":9}},{"FromLinter":"gofmt","Text":"File is not gofmt-ed","Severity":"error","SourceLines":["x  := 1"],"Replacement":{"NeedOnlyDelete":false,"NewLines":["x := 1"],"Inline":null},"Pos":{"Filename":"main.go","Offset":0,"Line":7,"Column":1}}],"Report":{"Linters":[]}}
+level=warning msg="[runner] Can't run linter goanalysis_metalinter"
+`
+
+func TestGolangciParser(t *testing.T) {
+  p, _ := GetParser("golangci")
+  cls := p.Parse("some preamble\n" + golangci_out)
+  t.Log(cls)
+  if len(cls) != 2 {
+    t.Fatal("Parsed", len(cls), "issues, expected 2")
+  }
+  if cls[0].FileName != "store/store.go" || cls[0].Line != 22 ||
+    cls[0].Column != 9 || cls[0].Code != "errcheck" ||
+    cls[0].Severity != SeverityWarning || len(cls[0].Fixes) != 0 {
+    t.Error("First issue", cls[0])
+  }
+  if cls[1].Severity != SeverityError || len(cls[1].Fixes) != 1 ||
+    cls[1].Fixes[0].Edits[0].NewText != "x := 1" ||
+    cls[1].Fixes[0].Edits[0].Line != 7 || cls[1].Fixes[0].Edits[0].EndLine != 7 {
+    t.Error("Second issue", cls[1])
+  }
+}
+
+var staticcheck_out string = `{"code":"SA4006","severity":"error","location":{"file":"/src/calc.go","line":12,"column":2},"end":{"file":"/src/calc.go","line":12,"column":5},"message":"this value of err is never used","related":[{"location":{"file":"/src/calc.go","line":14,"column":2},"end":{"file":"","line":0,"column":0},"message":"overwritten here"}]}
+{"code":"ST1005","severity":"warning","location":{"file":"/src/err.go","line":3,"column":8},"end":{"file":"/src/err.go","line":3,"column":30},"message":"error strings should not be capitalized"}
+`
+
+func TestStaticcheckParser(t *testing.T) {
+  p, _ := GetParser("staticcheck")
+  cls := p.Parse(staticcheck_out)
+  t.Log(cls)
+  if len(cls) != 2 {
+    t.Fatal("Parsed", len(cls), "issues, expected 2")
+  }
+  if cls[0].Code != "SA4006" || cls[0].Severity != SeverityError ||
+    cls[0].Line != 12 || len(cls[0].Related) != 1 ||
+    cls[0].Related[0].Line != 14 {
+    t.Error("First issue", cls[0])
+  }
+  if cls[1].Severity != SeverityWarning || cls[1].FileName != "/src/err.go" {
+    t.Error("Second issue", cls[1])
+  }
+}
+
+var vet_out string = `# example.com/calc
+{
+	"example.com/calc": {
+		"printf": [
+			{
+				"posn": "/src/calc/calc.go:9:2",
+				"message": "fmt.Sprintf call has arguments but no formatting directives"
+			}
+		],
+		"unusedresult": {
+			"error": "analysis skipped"
+		}
+	}
+}
+# example.com/calc/cmd
+{
+	"example.com/calc/cmd": {
+		"stringintconv": [
+			{
+				"posn": "/src/calc/cmd/main.go:5:7",
+				"message": "conversion from int to string yields a string of one rune",
+				"suggested_fixes": [
+					{
+						"message": "Did you mean to convert a rune to a string?",
+						"edits": [
+							{
+								"filename": "/src/calc/cmd/main.go",
+								"start": 52,
+								"end": 58,
+								"new": "string(rune"
+							}
+						]
+					}
+				]
+			}
+		]
+	}
+}
+`
+
+func TestVetParser(t *testing.T) {
+  p, _ := GetParser("vet")
+  cls := p.Parse(vet_out)
+  t.Log(cls)
+  if len(cls) != 2 {
+    t.Fatal("Parsed", len(cls), "issues, expected 2")
+  }
+  if cls[0].FileName != "/src/calc/calc.go" || cls[0].Line != 9 ||
+    cls[0].Column != 2 || cls[0].Code != "printf" || cls[0].Tool != "go vet" {
+    t.Error("First issue", cls[0])
+  }
+  if len(cls[1].Fixes) != 1 || cls[1].Fixes[0].Edits[0].Start != 52 ||
+    cls[1].Fixes[0].Edits[0].NewText != "string(rune" {
+    t.Error("Second issue fixes", cls[1].Fixes)
+  }
+}
+
+func TestIsGoVetJSON(t *testing.T) {
+  if !IsGoVetJSON(CommandDef{Name: "go", Args: []string{"vet", "-json", "./..."}}) {
+    t.Error("Expected go vet -json")
+  }
+  if IsGoVetJSON(CommandDef{Name: "go", Args: []string{"test", "-json"}}) {
+    t.Error("Expected go test")
+  }
+}
@@ -0,0 +1,151 @@
+package backend
+
+import (
+  "errors"
+  "fmt"
+  "strings"
+  "sync"
+  "time"
+)
+
+var ErrUnknownStream = errors.New("Unknown output stream")
+
+// OutputStream picks which of a command's output streams to use.
+type OutputStream int
+
+const (
+  // Both streams, interleaved in the order lines were written
+  StreamCombined OutputStream = iota
+  StreamStdout
+  StreamStderr
+)
+
+func (s OutputStream) String() string {
+  switch s {
+  case StreamStdout:
+    return "stdout"
+  case StreamStderr:
+    return "stderr"
+  default:
+    return "combined"
+  }
+}
+
+// ParseOutputStream reads a stream name, as used by Parser.stream.
+func ParseOutputStream(s string) (OutputStream, error) {
+  switch strings.ToLower(strings.TrimSpace(s)) {
+  case "", "combined", "both", "all":
+    return StreamCombined, nil
+  case "stdout", "out":
+    return StreamStdout, nil
+  case "stderr", "err":
+    return StreamStderr, nil
+  }
+  return StreamCombined, fmt.Errorf("%w: %s", ErrUnknownStream, s)
+}
+
+type OutputLine struct {
+  Stream OutputStream
+  // When the first of the line was written
+  Time time.Time
+  // Text of the line including its newline, which only the last line of a
+  // stream may be missing
+  Text string
+}
+
+// CommandOutput holds the lines a command wrote to stdout and stderr, in the
+// order they were written.
+type CommandOutput struct {
+  Lines []OutputLine
+  // Time limit the command was stopped at, zero if it finished by itself
+  Timeout time.Duration
+}
+
+// Text returns the output written to stream, or all output for
+// StreamCombined.
+func (o *CommandOutput) Text(stream OutputStream) string {
+  if o == nil {
+    return ""
+  }
+  var b strings.Builder
+  for _, l := range o.Lines {
+    if stream == StreamCombined || l.Stream == stream {
+      b.WriteString(l.Text)
+    }
+  }
+  return b.String()
+}
+
+func (o *CommandOutput) String() string {
+  return o.Text(StreamCombined)
+}
+
+// outputRecorder splits what a command writes to each stream into lines,
+// passing each line to send as it completes.
+type outputRecorder struct {
+  mutex sync.Mutex
+  output CommandOutput
+  // Partial last line of each stream
+  partial map[OutputStream]*OutputLine
+  send func(OutputLine)
+}
+
+func newOutputRecorder(send func(OutputLine)) *outputRecorder {
+  r := new(outputRecorder)
+  r.partial = map[OutputStream]*OutputLine{}
+  r.send = send
+  return r
+}
+
+func (r *outputRecorder) write(stream OutputStream, b []byte) {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+  text := string(b)
+  for text != "" {
+    line := r.partial[stream]
+    if line == nil {
+      line = &OutputLine{Stream: stream, Time: time.Now()}
+      r.partial[stream] = line
+    }
+    i := strings.IndexByte(text, '\n')
+    if i == -1 {
+      line.Text += text
+      return
+    }
+    line.Text += text[:i + 1]
+    text = text[i + 1:]
+    r.add(*line)
+    delete(r.partial, stream)
+  }
+}
+
+func (r *outputRecorder) add(line OutputLine) {
+  r.output.Lines = append(r.output.Lines, line)
+  if r.send != nil {
+    r.send(line)
+  }
+}
+
+// finish adds lines left without a newline and returns the whole output.
+func (r *outputRecorder) finish() *CommandOutput {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+  for _, stream := range []OutputStream{StreamStdout, StreamStderr} {
+    if line := r.partial[stream]; line != nil {
+      r.add(*line)
+      delete(r.partial, stream)
+    }
+  }
+  return &r.output
+}
+
+// streamWriter writes to one of a recorder's streams.
+type streamWriter struct {
+  recorder *outputRecorder
+  stream OutputStream
+}
+
+func (w streamWriter) Write(b []byte) (int, error) {
+  w.recorder.write(w.stream, b)
+  return len(b), nil
+}
@@ -0,0 +1,47 @@
+package backend
+
+import (
+  "testing"
+)
+
+func TestOutputRecorder(t *testing.T) {
+  sent := make([]OutputLine, 0)
+  r := newOutputRecorder(func(line OutputLine) {
+    sent = append(sent, line)
+  })
+  stdout, stderr := streamWriter{r, StreamStdout}, streamWriter{r, StreamStderr}
+  stdout.Write([]byte("building"))
+  stderr.Write([]byte("a.c:1:1: error: bad\nnote: "))
+  stdout.Write([]byte("... done\nlinking\n"))
+  stderr.Write([]byte("here"))
+  if len(sent) != 3 {
+    t.Fatal("Expected 3 complete lines before finish, got", sent)
+  }
+  output := r.finish()
+  t.Log(output.Lines)
+  if len(output.Lines) != 4 || output.Lines[3].Text != "note: here" {
+    t.Fatal("Lines", output.Lines)
+  }
+  if output.Text(StreamStdout) != "building... done\nlinking\n" {
+    t.Error("Stdout", output.Text(StreamStdout))
+  }
+  if output.Text(StreamStderr) != "a.c:1:1: error: bad\nnote: here" {
+    t.Error("Stderr", output.Text(StreamStderr))
+  }
+  // Lines are ordered by when they completed
+  if output.String() != "a.c:1:1: error: bad\nbuilding... done\nlinking\nnote: here" {
+    t.Error("Combined", output.String())
+  }
+}
+
+func TestParseOutputStream(t *testing.T) {
+  for name, expected := range map[string]OutputStream{"": StreamCombined,
+    "stdout": StreamStdout, "STDERR": StreamStderr, "both": StreamCombined} {
+    if s, err := ParseOutputStream(name); err != nil || s != expected {
+      t.Error("Parsing", name, "got", s, err)
+    }
+  }
+  if _, err := ParseOutputStream("stdin"); err == nil {
+    t.Error("Expected error for stdin")
+  }
+}
@@ -5,38 +5,295 @@ import (
   "strconv"
   "regexp"
   "fmt"
-  //"path/filepath"
+  "sort"
+  "errors"
 )
 
-// Golang specific regexp stuff - this needs to go
+var ErrUnknownParser = errors.New("Unknown parser format")
+var ErrBadPattern = errors.New("Parser pattern must have file and line groups")
+var ErrUnknownSeverity = errors.New("Unknown severity")
 
-var fullReg string = `(?m)(%s.+?):(\d+?):\d+?:(.*?)`
+type Severity int
+
+const (
+  SeverityError Severity = iota
+  SeverityWarning
+  SeverityNote
+)
+
+func (s Severity) String() string {
+  switch s {
+  case SeverityWarning:
+    return "warning"
+  case SeverityNote:
+    return "note"
+  default:
+    return "error"
+  }
+}
+
+// ParseSeverity maps the severity names used by common tools onto Severity.
+func ParseSeverity(s string) (Severity, error) {
+  switch strings.ToLower(strings.TrimSpace(s)) {
+  case "error", "err", "e", "fatal", "fatal error":
+    return SeverityError, nil
+  case "warning", "warn", "w":
+    return SeverityWarning, nil
+  case "note", "info", "remark", "hint", "n", "i":
+    return SeverityNote, nil
+  default:
+    return SeverityError, fmt.Errorf("%w: %s", ErrUnknownSeverity, s)
+  }
+}
+
+// toolSeverity reads a severity from tool output, where anything
+// unrecognised is treated as an error.
+func toolSeverity(s string) Severity {
+  severity, _ := ParseSeverity(s)
+  return severity
+}
 
 type CompileLine struct {
   FileName string
   Line int
+  Column int
+  Severity Severity
+  // Tool specific identifier of the diagnostic, e.g. E0425 or -Wunused
+  Code string
+  // Name of the tool which produced the diagnostic
+  Tool string
   Message string
+  // Notes and context belonging to this diagnostic
+  Related []CompileLine
+  // Stack of the panic or exception this diagnostic reports, innermost first
+  Frames []StackFrame
+  // Changes the tool suggests to resolve the diagnostic
+  Fixes []SuggestedFix
 }
 
-func Parse(output string, root string) []CompileLine {
-  re, err := regexp.Compile(fmt.Sprintf(fullReg, root))
-  cls := make([]CompileLine, 0, strings.Count(output, "\n"))
+// SortBySeverity orders cls with errors first, keeping the output order
+// within each severity.
+func SortBySeverity(cls []CompileLine) {
+  sort.SliceStable(cls, func(i, j int) bool {
+    return cls[i].Severity < cls[j].Severity
+  })
+}
+
+// FilterSeverity returns the diagnostics in cls at least as severe as min.
+func FilterSeverity(cls []CompileLine, min Severity) []CompileLine {
+  filtered := make([]CompileLine, 0, len(cls))
+  for _, cl := range cls {
+    if cl.Severity <= min {
+      filtered = append(filtered, cl)
+    }
+  }
+  return filtered
+}
+
+// Parser turns the full output of a command into the diagnostics it contains.
+type Parser interface {
+  Parse(output string) []CompileLine
+}
+
+// regexParser matches each line of output against a list of patterns, first
+// match wins. Patterns use the named groups file, line, col, severity, code
+// and message. Lines following a match which match cont are appended to its
+// message.
+type regexParser struct {
+  tool string
+  patterns []*regexp.Regexp
+  cont *regexp.Regexp
+}
+
+func newRegexParser(tool, cont string, patterns ...string) *regexParser {
+  p := new(regexParser)
+  p.tool = tool
+  for _, v := range patterns {
+    p.patterns = append(p.patterns, regexp.MustCompile(v))
+  }
+  if cont != "" {
+    p.cont = regexp.MustCompile(cont)
+  }
+  return p
+}
+
+// NewRegexParser builds a parser from a user supplied pattern using the named
+// groups file, line, col, severity, code and message, of which file and line
+// are required. cont may be empty. Diagnostics are reported as coming from
+// tool.
+func NewRegexParser(tool, pattern, cont string) (Parser, error) {
+  re, err := regexp.Compile(pattern)
   if err != nil {
-    return cls
+    return nil, err
+  }
+  if re.SubexpIndex("file") == -1 || re.SubexpIndex("line") == -1 {
+    return nil, fmt.Errorf("%w: %s", ErrBadPattern, pattern)
+  }
+  p := &regexParser{tool: tool, patterns: []*regexp.Regexp{re}}
+  if cont != "" {
+    p.cont, err = regexp.Compile(cont)
+    if err != nil {
+      return nil, err
+    }
   }
+  return p, nil
+}
 
-  matches := re.FindAllStringSubmatchIndex(output, -1)
+func (p *regexParser) Parse(output string) []CompileLine {
+  return scanAll(p.NewScanner(), output)
+}
 
-  for i, match := range matches {
-    cls = append(cls, CompileLine{})
-    cls[i].FileName = output[match[2]:match[3]]
-    cls[i].Line, _ = strconv.Atoi(output[match[4]:match[5]])
-    if i + 1 != len(matches) {
-      cls[i].Message = output[match[6]:matches[i+1][0]]
-    } else {
-      cls[i].Message = output[match[6]:]
+func (p *regexParser) NewScanner() LineScanner {
+  return &regexScanner{p: p}
+}
+
+type regexScanner struct {
+  p *regexParser
+  // Diagnostic which may still have continuation lines to come
+  pending *CompileLine
+}
+
+func (s *regexScanner) Line(line string) []CompileLine {
+  if cl, ok := s.p.match(line); ok {
+    done := s.Flush()
+    s.pending = &cl
+    return done
+  }
+  if s.pending != nil && s.p.cont != nil && s.p.cont.MatchString(line) {
+    s.pending.Message += "\n" + line
+    return nil
+  }
+  return s.Flush()
+}
+
+func (s *regexScanner) Flush() []CompileLine {
+  if s.pending == nil {
+    return nil
+  }
+  cl := *s.pending
+  s.pending = nil
+  return []CompileLine{cl}
+}
+
+func (p *regexParser) match(line string) (CompileLine, bool) {
+  for _, re := range p.patterns {
+    match := re.FindStringSubmatch(line)
+    if match == nil {
+      continue
     }
+    cl := CompileLine{Tool: p.tool}
+    for i, name := range re.SubexpNames() {
+      switch name {
+      case "file":
+        cl.FileName = match[i]
+      case "line":
+        cl.Line, _ = strconv.Atoi(match[i])
+      case "col":
+        cl.Column, _ = strconv.Atoi(match[i])
+      case "severity":
+        cl.Severity = toolSeverity(match[i])
+      case "code":
+        cl.Code = match[i]
+      case "message":
+        cl.Message = strings.TrimSpace(match[i])
+      }
+    }
+    return cl, true
   }
+  return CompileLine{}, false
+}
 
+// rustParser handles rustc's two line diagnostics, where the message comes
+// first and the location follows on a --> line.
+type rustParser struct {}
+
+var rustHeadReg = regexp.MustCompile(`^(error|warning|note|help)(?:\[(\w+)\])?: (.*)$`)
+var rustLocReg = regexp.MustCompile(`^\s*--> (.+?):(\d+):(\d+)$`)
+
+func (p rustParser) Parse(output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  var head []string
+  for _, line := range strings.Split(output, "\n") {
+    if match := rustHeadReg.FindStringSubmatch(line); match != nil {
+      head = match
+    } else if match := rustLocReg.FindStringSubmatch(line); match != nil &&
+      head != nil {
+      cl := CompileLine{FileName: match[1], Severity: toolSeverity(head[1]),
+        Code: head[2], Tool: "rustc", Message: head[3]}
+      cl.Line, _ = strconv.Atoi(match[2])
+      cl.Column, _ = strconv.Atoi(match[3])
+      cls = append(cls, cl)
+      head = nil
+    }
+  }
   return cls
 }
+
+var parsers = map[string]Parser{
+  "go": newGoParser(),
+  "gopanic": panicParser{},
+  "gcc": newGccParser(),
+  "gotest": goTestParser{},
+  "rustc": rustParser{},
+  "javac": newRegexParser("javac", "",
+    `^(?P<file>[^\s:][^:]*\.java):(?P<line>\d+): (?P<severity>error|warning): (?P<message>.*)$`),
+  "tsc": newRegexParser("tsc", "",
+    `^(?P<file>[^\s(][^(]*)\((?P<line>\d+),(?P<col>\d+)\): (?P<severity>error|warning) (?P<code>TS\d+): (?P<message>.*)$`,
+    `^(?P<file>[^\s:][^:]*):(?P<line>\d+):(?P<col>\d+) - (?P<severity>error|warning) (?P<code>TS\d+): (?P<message>.*)$`),
+  "python": pythonParser{},
+  "jvm": jvmParser{},
+  "golangci-lint": golangciParser{},
+  "staticcheck": staticcheckParser{},
+  "govet": vetParser{},
+}
+
+// Other names commonly used for the built in formats, which are also the
+// commands that imply them when Parser.format is not set.
+var parserAliases = map[string]string{
+  "golang": "go",
+  "go-test": "gotest",
+  "panic": "gopanic",
+  "cc": "gcc",
+  "c++": "gcc",
+  "clang": "gcc",
+  "g++": "gcc",
+  "clang++": "gcc",
+  "rust": "rustc",
+  "cargo": "rustc",
+  "java": "jvm",
+  "kotlin": "jvm",
+  "stacktrace": "jvm",
+  "typescript": "tsc",
+  "py": "python",
+  "python3": "python",
+  "golangci": "golangci-lint",
+  "go-vet": "govet",
+  "vet": "govet",
+}
+
+func GetParser(name string) (Parser, error) {
+  if alias, ok := parserAliases[name]; ok {
+    name = alias
+  }
+  p, ok := parsers[name]
+  if !ok {
+    return nil, fmt.Errorf("%w: %s, expected one of %s", ErrUnknownParser,
+      name, strings.Join(parserNames(), ", "))
+  }
+  return p, nil
+}
+
+func parserNames() []string {
+  names := make([]string, 0, len(parsers))
+  for k := range parsers {
+    names = append(names, k)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// Parse extracts Go diagnostics from output, resolving file names against
+// root.
+func Parse(output string, root string) []CompileLine {
+  return NewPathResolver(root, root).Parse(parsers["go"], output)
+}
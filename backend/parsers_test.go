@@ -2,7 +2,8 @@ package backend
 
 import (
   "testing"
-  //"regexp"
+  "errors"
+  "reflect"
 )
 
 var pretend_out string = `
@@ -17,21 +18,204 @@ src/github.com/MikeKneeB/coco/backend/parsers.go:35:18: undefined: msgReg
 src/github.com/MikeKneeB/coco/backend/parsers.go:36:7: cl.MessageLines undefined (type CompileLine has no field or method MessageLines)
 `
 
-func TestRegExps(t *testing.T) {
+var gcc_out string = `
+main.cpp: In function 'int main()':
+main.cpp:4:5: error: 'foo' was not declared in this scope
+    4 |     foo();
+      |     ^~~
+src/util.c:12: warning: unused variable 'x'
+`
+
+var rust_out string = `
+error[E0425]: cannot find value ` + "`y`" + ` in this scope
+ --> src/main.rs:3:13
+  |
+3 |     let x = y;
+  |             ^ not found in this scope
+`
+
+var javac_out string = `
+Hello.java:5: error: ';' expected
+        System.out.println("hi")
+                                ^
+1 error
+`
+
+var tsc_out string = `
+src/app.ts(10,5): error TS2322: Type 'string' is not assignable to type 'number'.
+src/other.ts:3:1 - error TS2304: Cannot find name 'foo'.
+`
+
+var python_out string = `
+Traceback (most recent call last):
+  File "tool.py", line 10, in <module>
+    main()
+  File "tool.py", line 6, in main
+    raise ValueError("bad")
+ValueError: bad
+`
+
+func TestParse(t *testing.T) {
+  cls := Parse(pretend_out, "/")
+  t.Log(cls)
+  if len(cls) != 7 {
+    t.Fatal("Parsed", len(cls), "lines, expected 7")
+  }
+  if cls[0].FileName != "/src/github.com/MikeKneeB/coco/backend/parsers.go" ||
+    cls[0].Line != 28 || cls[0].Message != "undefined: output_split" {
+    t.Error("First line", cls[0])
+  }
+  if cls[6].Line != 36 {
+    t.Error("Last line", cls[6].Line, "expected 36")
+  }
+}
+
+func TestParsers(t *testing.T) {
+  cases := []struct {
+    format, output string
+    expected CompileLine
+  }{
+    {"gcc", gcc_out, CompileLine{FileName: "main.cpp", Line: 4, Column: 5,
+      Tool: "gcc", Message: "'foo' was not declared in this scope",
+      Related: []CompileLine{CompileLine{FileName: "main.cpp",
+        Severity: SeverityNote, Tool: "gcc",
+        Message: "In function 'int main()'"}}}},
+    {"rustc", rust_out, CompileLine{FileName: "src/main.rs", Line: 3, Column: 13,
+      Code: "E0425", Tool: "rustc",
+      Message: "cannot find value `y` in this scope"}},
+    {"javac", javac_out, CompileLine{FileName: "Hello.java", Line: 5,
+      Tool: "javac", Message: "';' expected"}},
+    {"tsc", tsc_out, CompileLine{FileName: "src/app.ts", Line: 10, Column: 5,
+      Code: "TS2322", Tool: "tsc",
+      Message: "Type 'string' is not assignable to type 'number'."}},
+  }
+  for _, c := range cases {
+    p, err := GetParser(c.format)
+    if err != nil {
+      t.Fatal(err)
+    }
+    cls := p.Parse(c.output)
+    t.Log(c.format, cls)
+    if len(cls) == 0 || !reflect.DeepEqual(cls[0], c.expected) {
+      t.Error(c.format, "parsed", cls, "expected first", c.expected)
+    }
+  }
+}
+
+func TestGetParser(t *testing.T) {
+  p, err := GetParser("clang")
+  if err != nil || p != parsers["gcc"] {
+    t.Error("Expected clang alias for gcc parser")
+  }
+
+  _, err = GetParser("not-a-parser")
+  t.Log(err)
+  if !errors.Is(err, ErrUnknownParser) {
+    t.Error("Expected unknown parser error, got", err)
+  }
+}
+
+var custom_out string = `
+generating...
+[ERROR] schema/user.proto line 7: unknown type Usr
+> did you mean User?
+[WARN] schema/order.proto line 12: field never used
+done
+`
+
+func TestNewRegexParser(t *testing.T) {
+  p, err := NewRegexParser("gen",
+    `^\[(?P<severity>\w+)\] (?P<file>[^ ]+) line (?P<line>\d+): (?P<message>.*)$`,
+    `^>`)
+  if err != nil {
+    t.Fatal(err)
+  }
+  cls := p.Parse(custom_out)
+  t.Log(cls)
+  if len(cls) != 2 {
+    t.Fatal("Parsed", len(cls), "lines, expected 2")
+  }
+  if cls[0].FileName != "schema/user.proto" || cls[0].Line != 7 ||
+    cls[0].Message != "unknown type Usr\n> did you mean User?" {
+    t.Error("First line", cls[0])
+  }
+  if cls[1].Message != "field never used" ||
+    cls[1].Severity != SeverityWarning || cls[1].Tool != "gen" {
+    t.Error("Second line", cls[1])
+  }
+}
+
+func TestNewRegexParserBad(t *testing.T) {
+  _, err := NewRegexParser("gen", `(?P<file>\S+) (?P<message>.*)`, "")
+  t.Log(err)
+  if !errors.Is(err, ErrBadPattern) {
+    t.Error("Expected missing group error, got", err)
+  }
+
+  _, err = NewRegexParser("gen", `(?P<file>\S+):(?P<line>\d+`, "")
+  t.Log(err)
+  if err == nil {
+    t.Error("Expected compile error")
+  }
+
+  _, err = NewRegexParser("gen", `(?P<file>\S+):(?P<line>\d+)`, "(")
+  t.Log(err)
+  if err == nil {
+    t.Error("Expected continuation compile error")
+  }
+}
 
-  matches := fullReg.FindAllStringSubmatchIndex(pretend_out, -1)
+func TestGccSeverity(t *testing.T) {
+  p, _ := GetParser("gcc")
+  cls := p.Parse(gcc_out + "x.c:3:9: warning: unused variable 'y' [-Wunused-variable]\n")
+  t.Log(cls)
+  if len(cls) != 3 {
+    t.Fatal("Parsed", len(cls), "lines, expected 3")
+  }
+  if cls[1].Severity != SeverityWarning || cls[1].Column != 0 {
+    t.Error("Second line", cls[1])
+  }
+  if cls[2].Code != "-Wunused-variable" || cls[2].Message != "unused variable 'y'" {
+    t.Error("Third line", cls[2])
+  }
+}
 
-  t.Log(len(matches))
-  t.Log(matches)
+func TestParseSeverity(t *testing.T) {
+  cases := map[string]Severity{"error": SeverityError, "Warning": SeverityWarning,
+    "note": SeverityNote, "info": SeverityNote, "fatal": SeverityError}
+  for k, v := range cases {
+    if s, err := ParseSeverity(k); s != v || err != nil {
+      t.Error("Severity of", k, "was", s, err, "expected", v)
+    }
+  }
+  if _, err := ParseSeverity("warnig"); !errors.Is(err, ErrUnknownSeverity) {
+    t.Error("Expected ErrUnknownSeverity, got", err)
+  }
+  if toolSeverity("") != SeverityError {
+    t.Error("Expected unknown tool severities to be errors")
+  }
+}
 
-  for i, match := range matches {
-    t.Log(pretend_out[match[2]:match[3]])
-    t.Log(pretend_out[match[4]:match[5]])
-    if i + 1 != len(matches) {
-      t.Log(pretend_out[match[6]:matches[i+1][0]])
-    } else {
-      t.Log(pretend_out[match[6]:])
+func TestSortFilterSeverity(t *testing.T) {
+  cls := []CompileLine{
+    CompileLine{Line: 1, Severity: SeverityNote},
+    CompileLine{Line: 2, Severity: SeverityWarning},
+    CompileLine{Line: 3, Severity: SeverityError},
+    CompileLine{Line: 4, Severity: SeverityWarning},
+  }
+  SortBySeverity(cls)
+  order := []int{3, 2, 4, 1}
+  for i, v := range order {
+    if cls[i].Line != v {
+      t.Error("Sorted order", cls, "expected lines", order)
+      break
     }
   }
 
+  if filtered := FilterSeverity(cls, SeverityWarning); len(filtered) != 3 {
+    t.Error("Filtered", filtered, "expected 3 lines")
+  }
+  if filtered := FilterSeverity(cls, SeverityError); len(filtered) != 1 {
+    t.Error("Filtered", filtered, "expected 1 line")
+  }
 }
@@ -0,0 +1,95 @@
+package backend
+
+import (
+  "os"
+  "path/filepath"
+  "regexp"
+  "strings"
+)
+
+// PathResolver makes the file names in diagnostics absolute. Relative names
+// are resolved against the directory the command was in when it printed them,
+// following make and ninja "Entering directory" messages, falling back to the
+// project root for names which do not exist there.
+type PathResolver struct {
+  Root string
+  Dir string
+}
+
+var enterDirReg = regexp.MustCompile(
+  "^(?:g?make|ninja)(?:\\[\\d+\\])?: Entering directory [`'\"](.+)['\"]$")
+var leaveDirReg = regexp.MustCompile(
+  "^g?make(?:\\[\\d+\\])?: Leaving directory [`'\"](.+)['\"]$")
+
+// NewPathResolver resolves names against dir, the directory the command runs
+// in, and root. Both are made absolute relative to the working directory.
+func NewPathResolver(root, dir string) *PathResolver {
+  r := new(PathResolver)
+  r.Root, _ = filepath.Abs(root)
+  r.Dir, _ = filepath.Abs(dir)
+  return r
+}
+
+// Parse runs p over output, resolving the file names it finds.
+func (r *PathResolver) Parse(p Parser, output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  s := NewStreamParser(p, r, func(found []CompileLine) {
+    cls = append(cls, found...)
+  })
+  // All the output is here already, so parse it once
+  s.interval = -1
+  s.Write([]byte(output))
+  s.Close()
+  return cls
+}
+
+// Resolve makes the file names in cls absolute relative to the resolver's
+// directory, for diagnostics which did not come from command output.
+func (r *PathResolver) Resolve(cls []CompileLine) []CompileLine {
+  return r.resolveAll(cls, r.Dir)
+}
+
+func (r *PathResolver) resolveAll(cls []CompileLine, dir string) []CompileLine {
+  for i := range cls {
+    cl := &cls[i]
+    cl.FileName = r.resolve(cl.FileName, dir)
+    cl.Related = r.resolveAll(cl.Related, dir)
+    for j := range cl.Frames {
+      cl.Frames[j].File = r.resolve(cl.Frames[j].File, dir)
+    }
+    for _, fix := range cl.Fixes {
+      for j := range fix.Edits {
+        fix.Edits[j].FileName = r.resolve(fix.Edits[j].FileName, dir)
+      }
+    }
+  }
+  return cls
+}
+
+func (r *PathResolver) resolve(name, dir string) string {
+  if name == "" || strings.HasPrefix(name, "<") {
+    return name
+  }
+  path := r.join(dir, name)
+  if !filepath.IsAbs(name) && !exists(path) && r.Root != "" {
+    if rooted := r.join(r.Root, name); exists(rooted) {
+      path = rooted
+    }
+  }
+  if real, err := filepath.EvalSymlinks(path); err == nil {
+    return real
+  }
+  return path
+}
+
+func (r *PathResolver) join(dir, name string) string {
+  if filepath.IsAbs(name) {
+    return filepath.Clean(name)
+  }
+  return filepath.Join(dir, name)
+}
+
+func exists(path string) bool {
+  _, err := os.Stat(path)
+  return err == nil
+}
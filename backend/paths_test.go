@@ -0,0 +1,74 @@
+package backend
+
+import (
+  "testing"
+  "os"
+  "path/filepath"
+)
+
+func TestPathResolverMake(t *testing.T) {
+  root := t.TempDir()
+  os.MkdirAll(filepath.Join(root, "lib", "src"), 0755)
+  os.MkdirAll(filepath.Join(root, "build"), 0755)
+  os.WriteFile(filepath.Join(root, "lib", "src", "a.c"), []byte(""), 0644)
+  os.WriteFile(filepath.Join(root, "main.c"), []byte(""), 0644)
+
+  out := "make[1]: Entering directory '" + root + "/lib'\n" +
+    "src/a.c:3:1: error: first\n" +
+    "make[2]: Entering directory 'nested'\n" +
+    "b.c:4:1: error: second\n" +
+    "make[2]: Leaving directory 'nested'\n" +
+    "make[1]: Leaving directory '" + root + "/lib'\n" +
+    "main.c:5:1: error: third\n"
+  r := NewPathResolver(root, filepath.Join(root, "build"))
+  cls := r.Parse(parsers["gcc"], out)
+  t.Log(cls)
+  if len(cls) != 3 {
+    t.Fatal("Parsed", len(cls), "lines, expected 3")
+  }
+  real_root, _ := filepath.EvalSymlinks(root)
+  expected := []string{filepath.Join(real_root, "lib", "src", "a.c"),
+    filepath.Join(root, "lib", "nested", "b.c"),
+    filepath.Join(real_root, "main.c")}
+  for i, v := range expected {
+    if cls[i].FileName != v {
+      t.Error("File", cls[i].FileName, "expected", v)
+    }
+  }
+}
+
+func TestPathResolverNinja(t *testing.T) {
+  r := NewPathResolver("/project", "/project")
+  out := "ninja: Entering directory `out/debug'\n" +
+    "../../src/x.cc:1:2: error: bad\n"
+  cls := r.Parse(parsers["gcc"], out)
+  if len(cls) != 1 || cls[0].FileName != "/project/src/x.cc" {
+    t.Error("Parsed", cls)
+  }
+}
+
+func TestPathResolverSymlink(t *testing.T) {
+  root := t.TempDir()
+  os.MkdirAll(filepath.Join(root, "real"), 0755)
+  os.WriteFile(filepath.Join(root, "real", "x.go"), []byte(""), 0644)
+  os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "link"))
+
+  r := NewPathResolver(root, filepath.Join(root, "link"))
+  cls := r.Resolve([]CompileLine{CompileLine{FileName: "x.go",
+    Related: []CompileLine{CompileLine{FileName: "<stdin>"}}}})
+  real, _ := filepath.EvalSymlinks(filepath.Join(root, "real", "x.go"))
+  if cls[0].FileName != real || cls[0].Related[0].FileName != "<stdin>" {
+    t.Error("Resolved", cls)
+  }
+}
+
+func TestPathResolverFixes(t *testing.T) {
+  r := NewPathResolver("/project", "/project/sub")
+  cls := r.Resolve([]CompileLine{CompileLine{FileName: "x.go",
+    Fixes: []SuggestedFix{SuggestedFix{Edits: []TextEdit{
+      TextEdit{FileName: "x.go"}, TextEdit{FileName: "/abs/y.go"}}}}}})
+  edits := cls[0].Fixes[0].Edits
+  if edits[0].FileName != "/project/sub/x.go" || edits[1].FileName != "/abs/y.go" {
+    t.Error("Resolved edits", edits)
+  }
+}
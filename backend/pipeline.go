@@ -0,0 +1,346 @@
+package backend
+
+import (
+  "errors"
+  "fmt"
+  "path/filepath"
+  "strings"
+  "time"
+  "github.com/spf13/viper"
+)
+
+var ErrUnknownNeed = errors.New("Step needs unknown step")
+var ErrDuplicateStep = errors.New("Step name used more than once")
+var ErrNeedsCycle = errors.New("Steps need each other in a cycle")
+
+// Step is one named command of a pipeline, with the parser and resolver for
+// its output.
+type Step struct {
+  Name string
+  Command CommandDef
+  // What Command runs, the first command of a shell script rather than the
+  // shell, as used to name the step and choose its parser
+  Script CommandDef
+  Parser Parser
+  Resolver *PathResolver
+  // Output stream diagnostics are parsed from
+  Stream OutputStream
+  // Names of the steps which must succeed before this one runs
+  Needs []string
+}
+
+// Pipeline runs its steps in order each time coco is triggered, or as a graph
+// if any step names the steps it needs.
+type Pipeline struct {
+  // Name of the pipeline when there are several running side by side
+  Name string
+  Steps []Step
+  // Skip the remaining steps once one fails, graphs only skip the steps
+  // which need the failed one
+  StopOnFailure bool
+  // Time limit for each step, zero for none
+  Timeout time.Duration
+}
+
+// Graph reports whether steps of p run as soon as the steps they need have
+// passed, rather than in order.
+func (p Pipeline) Graph() bool {
+  for _, step := range p.Steps {
+    if len(step.Needs) != 0 {
+      return true
+    }
+  }
+  return false
+}
+
+// FirstStep is the step which starts each run, the first step with no needs.
+func (p Pipeline) FirstStep() int {
+  for i, step := range p.Steps {
+    if len(step.Needs) == 0 {
+      return i
+    }
+  }
+  return 0
+}
+
+type StepState int
+
+const (
+  StepWaiting StepState = iota
+  StepRunning
+  StepPassed
+  StepFailed
+  StepSkipped
+)
+
+func (s StepState) String() string {
+  switch s {
+  case StepRunning:
+    return "running"
+  case StepPassed:
+    return "ok"
+  case StepFailed:
+    return "failed"
+  case StepSkipped:
+    return "skipped"
+  default:
+    return "waiting"
+  }
+}
+
+// Schedule returns the waiting steps of a graph whose needs have all passed.
+// Waiting steps which need a failed or skipped step are marked skipped.
+func (p Pipeline) Schedule(states []StepState) []int {
+  index := p.stepIndex()
+  ready := make([]int, 0)
+  // Skipping a step may skip others which need it, so repeat until settled
+  for changed := true; changed; {
+    changed = false
+    ready = ready[:0]
+    for i, step := range p.Steps {
+      if states[i] != StepWaiting {
+        continue
+      }
+      passed := true
+      for _, need := range step.Needs {
+        switch states[index[need]] {
+        case StepFailed, StepSkipped:
+          states[i] = StepSkipped
+          changed = true
+        case StepPassed:
+        default:
+          passed = false
+        }
+        if states[i] == StepSkipped {
+          break
+        }
+      }
+      if passed && states[i] == StepWaiting {
+        ready = append(ready, i)
+      }
+    }
+  }
+  return ready
+}
+
+// GraphStatus lists the state of each step of p.
+func (p Pipeline) GraphStatus(states []StepState) string {
+  status := make([]string, 0, len(p.Steps))
+  for i, step := range p.Steps {
+    status = append(status, step.Name + " " + states[i].String())
+  }
+  return strings.Join(status, ", ")
+}
+
+func (p Pipeline) stepIndex() map[string]int {
+  index := map[string]int{}
+  for i, step := range p.Steps {
+    index[step.Name] = i
+  }
+  return index
+}
+
+// checkGraph makes sure every step needed exists, can be told apart by name,
+// and that no step ends up needing itself.
+func (p Pipeline) checkGraph() error {
+  index := p.stepIndex()
+  if len(index) != len(p.Steps) {
+    for i, step := range p.Steps {
+      if index[step.Name] != i {
+        return fmt.Errorf("%w: %s", ErrDuplicateStep, step.Name)
+      }
+    }
+  }
+  for _, step := range p.Steps {
+    for _, need := range step.Needs {
+      if _, ok := index[need]; !ok {
+        return fmt.Errorf("%w: %s needs %s", ErrUnknownNeed, step.Name, need)
+      }
+    }
+  }
+  // Steps which can never become ready are part of, or need, a cycle
+  states := make([]StepState, len(p.Steps))
+  for ready := p.Schedule(states); len(ready) != 0; ready = p.Schedule(states) {
+    for _, i := range ready {
+      states[i] = StepPassed
+    }
+  }
+  for i, state := range states {
+    if state == StepWaiting {
+      return fmt.Errorf("%w: %s", ErrNeedsCycle, p.Steps[i].Name)
+    }
+  }
+  return nil
+}
+
+// StepResult is the outcome of one step of a run.
+type StepResult struct {
+  Name string
+  Output *CommandOutput
+  ReturnCode int
+  // Set when the step could not be run at all
+  Err error
+  // The step was not run as an earlier step, or one it needs, failed
+  Skipped bool
+}
+
+func (r StepResult) Failed() bool {
+  return r.Err != nil || r.ReturnCode != 0
+}
+
+// FailedStep returns the first step in results which failed, or nil.
+func FailedStep(results []StepResult) *StepResult {
+  for i := range results {
+    if results[i].Failed() {
+      return &results[i]
+    }
+  }
+  return nil
+}
+
+// ReadPipelines reads the pipelines which run together on each trigger, one
+// for each entry of Commands, or else the single pipeline of PeriodicCommand.
+// Commands entries take the same settings as PeriodicCommand, along with a
+// name and their own parser section, and share its dir and timeout unless
+// they set their own.
+func ReadPipelines(viper *viper.Viper) ([]Pipeline, error) {
+  if !viper.IsSet("Commands") {
+    p, err := ReadPipeline(viper)
+    if err != nil {
+      return nil, err
+    }
+    return []Pipeline{p}, nil
+  }
+  confs := []map[string]interface{}{}
+  err := viper.UnmarshalKey("Commands", &confs)
+  if err != nil {
+    return nil, err
+  }
+  pipelines := make([]Pipeline, 0, len(confs))
+  for i, conf := range confs {
+    v := commandViper(viper, conf)
+    p, err := ReadPipeline(v)
+    if err != nil {
+      return nil, fmt.Errorf("Command %d: %w", i + 1, err)
+    }
+    p.Name = v.GetString("PeriodicCommand.name")
+    if p.Name == "" {
+      p.Name = p.Steps[0].Name
+    }
+    pipelines = append(pipelines, p)
+  }
+  return pipelines, nil
+}
+
+// commandViper gives an entry of Commands in place of PeriodicCommand, with its
+// parser section in place of Parser if it has one.
+func commandViper(parent *viper.Viper, conf map[string]interface{}) *viper.Viper {
+  v := viper.New()
+  settings := parent.AllSettings()
+  delete(settings, "commands")
+  pc := map[string]interface{}{"dir": parent.GetString("PeriodicCommand.dir")}
+  if parent.IsSet("PeriodicCommand.timeout") {
+    pc["timeout"] = parent.Get("PeriodicCommand.timeout")
+  }
+  for key, val := range conf {
+    pc[strings.ToLower(key)] = val
+  }
+  settings["periodiccommand"] = pc
+  if parser, ok := pc["parser"]; ok {
+    settings["parser"] = parser
+  }
+  v.MergeConfigMap(settings)
+  return v
+}
+
+type stepConf struct {
+  Name string
+  Command string
+  Shell string
+  Args []string
+  Dir string
+  Env []string
+  Parser map[string]interface{}
+  Needs []string
+}
+
+// ReadPipeline reads PeriodicCommand.steps, or makes a single step pipeline
+// from PeriodicCommand and Parser when there are no steps. Steps default to
+// PeriodicCommand.dir and the Parser section, and a parser inferred from
+// their command when there is no Parser section either. Steps may list the
+// steps they need by name, making the pipeline a graph.
+func ReadPipeline(viper *viper.Viper) (Pipeline, error) {
+  pipeline := Pipeline{StopOnFailure: true,
+    Timeout: ReadTimeout(viper, "PeriodicCommand")}
+  if viper.IsSet("PeriodicCommand.stop_on_failure") {
+    pipeline.StopOnFailure = viper.GetBool("PeriodicCommand.stop_on_failure")
+  }
+  if !viper.IsSet("PeriodicCommand.steps") {
+    step, err := readStep(viper)
+    if err != nil {
+      return pipeline, err
+    }
+    pipeline.Steps = []Step{step}
+    return pipeline, nil
+  }
+
+  confs := []stepConf{}
+  err := viper.UnmarshalKey("PeriodicCommand.steps", &confs)
+  if err != nil {
+    return pipeline, err
+  }
+  for i, conf := range confs {
+    if conf.Command == "" && conf.Shell == "" {
+      return pipeline, fmt.Errorf("Did not find command or shell for step %d in config file %s",
+                                  i + 1, viper.ConfigFileUsed())
+    }
+    step, err := readStep(stepViper(viper, conf))
+    if err != nil {
+      return pipeline, fmt.Errorf("Step %s: %w", step.Name, err)
+    }
+    if conf.Name != "" {
+      step.Name = conf.Name
+    }
+    step.Needs = conf.Needs
+    pipeline.Steps = append(pipeline.Steps, step)
+  }
+  if pipeline.Graph() {
+    err = pipeline.checkGraph()
+  }
+  return pipeline, err
+}
+
+func readStep(viper *viper.Viper) (Step, error) {
+  script := scriptCommand(viper)
+  step := Step{Name: filepath.Base(script.Name), Script: script}
+  var err error
+  step.Command, err = ReadPeriodicCommand(viper)
+  if err != nil {
+    return step, err
+  }
+  step.Parser, err = ReadParser(viper)
+  if err != nil {
+    return step, err
+  }
+  step.Resolver = ReadPathResolver(viper)
+  step.Stream, err = ParseOutputStream(viper.GetString("Parser.stream"))
+  return step, err
+}
+
+// stepViper gives a step's settings in place of PeriodicCommand, so that the
+// step can be read as if it were the only command.
+func stepViper(parent *viper.Viper, conf stepConf) *viper.Viper {
+  v := viper.New()
+  v.MergeConfigMap(parent.AllSettings())
+  v.Set("PeriodicCommand.command", conf.Command)
+  v.Set("PeriodicCommand.shell", conf.Shell)
+  v.Set("PeriodicCommand.args", append([]string{}, conf.Args...))
+  v.Set("PeriodicCommand.env", append([]string{}, conf.Env...))
+  if conf.Dir != "" {
+    v.Set("PeriodicCommand.dir", conf.Dir)
+  }
+  if conf.Parser != nil {
+    v.Set("Parser", conf.Parser)
+  }
+  return v
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package backend
+
+import (
+  "os/exec"
+  "syscall"
+  "time"
+)
+
+// setProcessGroup runs cmd in its own process group, so that cancelling it
+// stops everything it started. The group is sent SIGTERM, then SIGKILL if it
+// is still running after grace.
+func setProcessGroup(cmd *exec.Cmd, grace time.Duration) {
+  cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+  cmd.Cancel = func() error {
+    pgid := cmd.Process.Pid
+    err := syscall.Kill(-pgid, syscall.SIGTERM)
+    time.AfterFunc(grace, func() {
+      // Signal 0 only checks whether any of the group is left
+      if syscall.Kill(-pgid, 0) == nil {
+        syscall.Kill(-pgid, syscall.SIGKILL)
+      }
+    })
+    return err
+  }
+}
@@ -0,0 +1,11 @@
+package backend
+
+import (
+  "os/exec"
+  "time"
+)
+
+// setProcessGroup has no process groups to use on Windows, cancelling cmd
+// kills just the process.
+func setProcessGroup(cmd *exec.Cmd, grace time.Duration) {
+}
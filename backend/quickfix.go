@@ -0,0 +1,63 @@
+package backend
+
+import (
+  "fmt"
+  "io"
+  "strings"
+)
+
+// WriteQuickfix writes cls for Vim's :cfile, one file:line:col: message line
+// for each diagnostic and each of its related notes. Diagnostics without a
+// file are left out as there is nowhere to jump to.
+func WriteQuickfix(w io.Writer, cls []CompileLine) error {
+  return writeLocated(w, cls, func(cl CompileLine) string {
+    pos := fmt.Sprintf("%s:%d", cl.FileName, locatedLine(cl))
+    if cl.Column != 0 {
+      pos += fmt.Sprintf(":%d", cl.Column)
+    }
+    return pos
+  })
+}
+
+// WriteEmacs writes cls in the GNU file:line.col: format understood by Emacs
+// compilation mode.
+func WriteEmacs(w io.Writer, cls []CompileLine) error {
+  return writeLocated(w, cls, func(cl CompileLine) string {
+    pos := fmt.Sprintf("%s:%d", cl.FileName, locatedLine(cl))
+    if cl.Column != 0 {
+      pos += fmt.Sprintf(".%d", cl.Column)
+    }
+    return pos
+  })
+}
+
+// locatedLine gives diagnostics for a whole file its first line, so that
+// editors still recognise the location.
+func locatedLine(cl CompileLine) int {
+  if cl.Line == 0 {
+    return 1
+  }
+  return cl.Line
+}
+
+func writeLocated(w io.Writer, cls []CompileLine,
+  position func(CompileLine) string) error {
+  for _, cl := range cls {
+    if cl.FileName == "" {
+      continue
+    }
+    // Only the first line of the message fits the format
+    message := strings.SplitN(strings.TrimSpace(cl.Message), "\n", 2)[0]
+    if cl.Code != "" {
+      message += " [" + cl.Code + "]"
+    }
+    _, err := fmt.Fprintf(w, "%s: %s: %s\n", position(cl), cl.Severity, message)
+    if err != nil {
+      return err
+    }
+    if err := writeLocated(w, cl.Related, position); err != nil {
+      return err
+    }
+  }
+  return nil
+}
@@ -0,0 +1,50 @@
+package backend
+
+import (
+  "bytes"
+  "testing"
+)
+
+var locatedLines = []CompileLine{
+  CompileLine{FileName: "src/main.c", Line: 3, Column: 7, Message: "expected ';'\n   3 | int x\n",
+    Related: []CompileLine{CompileLine{FileName: "src/main.h", Line: 1,
+      Severity: SeverityNote, Message: "in file included from here"}}},
+  CompileLine{FileName: "go.mod", Severity: SeverityWarning,
+    Code: "directive", Message: "unused requirement"},
+  CompileLine{Message: "linker failed"},
+}
+
+func TestWriteQuickfix(t *testing.T) {
+  var out bytes.Buffer
+  if err := WriteQuickfix(&out, locatedLines); err != nil {
+    t.Fatal(err)
+  }
+  expected := "src/main.c:3:7: error: expected ';'\n" +
+    "src/main.h:1: note: in file included from here\n" +
+    "go.mod:1: warning: unused requirement [directive]\n"
+  if out.String() != expected {
+    t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+  }
+}
+
+func TestWriteEmacs(t *testing.T) {
+  var out bytes.Buffer
+  if err := WriteEmacs(&out, locatedLines[:1]); err != nil {
+    t.Fatal(err)
+  }
+  expected := "src/main.c:3.7: error: expected ';'\n" +
+    "src/main.h:1: note: in file included from here\n"
+  if out.String() != expected {
+    t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+  }
+}
+
+func TestQuickfixRoundTrip(t *testing.T) {
+  var out bytes.Buffer
+  WriteQuickfix(&out, locatedLines)
+  cls := parsers["gcc"].Parse(out.String())
+  t.Log(cls)
+  if len(cls) == 0 || cls[0].FileName != "src/main.c" || cls[0].Column != 7 {
+    t.Error("Expected quickfix output to parse back, got", cls)
+  }
+}
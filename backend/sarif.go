@@ -0,0 +1,211 @@
+package backend
+
+import (
+  "encoding/json"
+  "io"
+  "net/url"
+  "path/filepath"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+  Schema string `json:"$schema"`
+  Version string `json:"version"`
+  Runs []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+  Tool struct {
+    Driver struct {
+      Name string `json:"name"`
+      Rules []sarifRule `json:"rules,omitempty"`
+    } `json:"driver"`
+  } `json:"tool"`
+  Results []sarifResult `json:"results"`
+}
+
+type sarifRule struct {
+  ID string `json:"id"`
+}
+
+type sarifMessage struct {
+  Text string `json:"text"`
+}
+
+type sarifRegion struct {
+  StartLine int `json:"startLine,omitempty"`
+  StartColumn int `json:"startColumn,omitempty"`
+  EndLine int `json:"endLine,omitempty"`
+  ByteOffset *int `json:"byteOffset,omitempty"`
+  ByteLength *int `json:"byteLength,omitempty"`
+}
+
+type sarifArtifact struct {
+  URI string `json:"uri"`
+}
+
+type sarifPhysicalLocation struct {
+  ArtifactLocation sarifArtifact `json:"artifactLocation"`
+  Region *sarifRegion `json:"region,omitempty"`
+}
+
+type sarifLocation struct {
+  PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+  LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+  Message *sarifMessage `json:"message,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+  FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+type sarifFrame struct {
+  Location sarifLocation `json:"location"`
+}
+
+type sarifStack struct {
+  Frames []sarifFrame `json:"frames"`
+}
+
+type sarifReplacement struct {
+  DeletedRegion sarifRegion `json:"deletedRegion"`
+  InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+type sarifFix struct {
+  Description sarifMessage `json:"description"`
+  ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+  ArtifactLocation sarifArtifact `json:"artifactLocation"`
+  Replacements []sarifReplacement `json:"replacements"`
+}
+
+type sarifResult struct {
+  RuleID string `json:"ruleId,omitempty"`
+  Level string `json:"level"`
+  Message sarifMessage `json:"message"`
+  Locations []sarifLocation `json:"locations,omitempty"`
+  RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+  Stacks []sarifStack `json:"stacks,omitempty"`
+  Fixes []sarifFix `json:"fixes,omitempty"`
+}
+
+// WriteSARIF writes cls as a SARIF 2.1.0 log, with one run for each tool
+// which reported diagnostics.
+func WriteSARIF(w io.Writer, cls []CompileLine) error {
+  log := sarifLog{Schema: sarifSchema, Version: "2.1.0",
+    Runs: make([]*sarifRun, 0)}
+  runs := map[string]*sarifRun{}
+  rules := map[string]map[string]bool{}
+  for _, cl := range cls {
+    tool := cl.Tool
+    if tool == "" {
+      tool = "coco"
+    }
+    run, ok := runs[tool]
+    if !ok {
+      run = &sarifRun{Results: make([]sarifResult, 0)}
+      run.Tool.Driver.Name = tool
+      runs[tool] = run
+      rules[tool] = map[string]bool{}
+      log.Runs = append(log.Runs, run)
+    }
+    if cl.Code != "" && !rules[tool][cl.Code] {
+      rules[tool][cl.Code] = true
+      run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{cl.Code})
+    }
+    run.Results = append(run.Results, sarifResultOf(cl))
+  }
+  enc := json.NewEncoder(w)
+  enc.SetIndent("", "  ")
+  return enc.Encode(log)
+}
+
+func sarifResultOf(cl CompileLine) sarifResult {
+  result := sarifResult{RuleID: cl.Code, Message: sarifMessage{cl.Message}}
+  switch cl.Severity {
+  case SeverityWarning:
+    result.Level = "warning"
+  case SeverityNote:
+    result.Level = "note"
+  default:
+    result.Level = "error"
+  }
+  if cl.FileName != "" {
+    result.Locations = []sarifLocation{
+      sarifLocation{PhysicalLocation: sarifPhysical(cl.FileName, cl.Line,
+        cl.Column)}}
+  }
+  for _, r := range cl.Related {
+    result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+      PhysicalLocation: sarifPhysical(r.FileName, r.Line, r.Column),
+      Message: &sarifMessage{r.Message}})
+  }
+  if len(cl.Frames) != 0 {
+    stack := sarifStack{}
+    for _, f := range cl.Frames {
+      frame := sarifFrame{}
+      if f.File != "" {
+        frame.Location.PhysicalLocation = sarifPhysical(f.File, f.Line, 0)
+      }
+      if f.Function != "" {
+        frame.Location.LogicalLocations = []sarifLogicalLocation{
+          sarifLogicalLocation{f.Function}}
+      }
+      stack.Frames = append(stack.Frames, frame)
+    }
+    result.Stacks = []sarifStack{stack}
+  }
+  for _, f := range cl.Fixes {
+    result.Fixes = append(result.Fixes, sarifFixOf(f))
+  }
+  return result
+}
+
+func sarifFixOf(f SuggestedFix) sarifFix {
+  fix := sarifFix{Description: sarifMessage{f.Message}}
+  // Replacements are grouped by the file they change
+  changes := map[string]int{}
+  for _, e := range f.Edits {
+    i, ok := changes[e.FileName]
+    if !ok {
+      i = len(fix.ArtifactChanges)
+      changes[e.FileName] = i
+      fix.ArtifactChanges = append(fix.ArtifactChanges, sarifArtifactChange{
+        ArtifactLocation: sarifArtifact{sarifURI(e.FileName)}})
+    }
+    replacement := sarifReplacement{InsertedContent: &sarifMessage{e.NewText}}
+    if e.End != 0 {
+      offset, length := e.Start, e.End - e.Start
+      replacement.DeletedRegion = sarifRegion{ByteOffset: &offset,
+        ByteLength: &length}
+    } else {
+      replacement.DeletedRegion = sarifRegion{StartLine: e.Line,
+        EndLine: e.EndLine}
+    }
+    fix.ArtifactChanges[i].Replacements = append(
+      fix.ArtifactChanges[i].Replacements, replacement)
+  }
+  return fix
+}
+
+func sarifPhysical(name string, line, column int) *sarifPhysicalLocation {
+  loc := &sarifPhysicalLocation{ArtifactLocation: sarifArtifact{sarifURI(name)}}
+  if line > 0 {
+    loc.Region = &sarifRegion{StartLine: line, StartColumn: column}
+  }
+  return loc
+}
+
+// sarifURI gives absolute names a file URI, relative names are left as
+// relative references.
+func sarifURI(name string) string {
+  u := url.URL{Path: filepath.ToSlash(name)}
+  if filepath.IsAbs(name) {
+    u.Scheme = "file"
+  }
+  return u.String()
+}
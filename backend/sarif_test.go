@@ -0,0 +1,80 @@
+package backend
+
+import (
+  "bytes"
+  "encoding/json"
+  "os"
+  "testing"
+)
+
+func TestWriteSARIF(t *testing.T) {
+  cls := []CompileLine{
+    CompileLine{FileName: "/src/main.go", Line: 4, Column: 2,
+      Severity: SeverityWarning, Code: "SA4006", Tool: "staticcheck",
+      Message: "value never used",
+      Related: []CompileLine{CompileLine{FileName: "/src/main.go", Line: 2,
+        Severity: SeverityNote, Message: "assigned here"}}},
+    CompileLine{FileName: "main.go", Line: 9, Tool: "go", Message: "undefined: x",
+      Fixes: []SuggestedFix{SuggestedFix{Message: "Define x",
+        Edits: []TextEdit{TextEdit{FileName: "main.go", Start: 10, End: 11,
+          NewText: "y"}}}}},
+    CompileLine{Tool: "go", Message: "panic: oops",
+      Frames: []StackFrame{StackFrame{Function: "main.main", File: "main.go",
+        Line: 12}}},
+  }
+  var out bytes.Buffer
+  if err := WriteSARIF(&out, cls); err != nil {
+    t.Fatal(err)
+  }
+  t.Log(out.String())
+
+  log := sarifLog{}
+  if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+    t.Fatal(err)
+  }
+  if log.Version != "2.1.0" || len(log.Runs) != 2 {
+    t.Fatal("Expected 2.1.0 log with 2 runs, got", log.Version, len(log.Runs))
+  }
+  sc := log.Runs[0]
+  if sc.Tool.Driver.Name != "staticcheck" || len(sc.Tool.Driver.Rules) != 1 ||
+    len(sc.Results) != 1 {
+    t.Fatal("staticcheck run", sc)
+  }
+  r := sc.Results[0]
+  if r.RuleID != "SA4006" || r.Level != "warning" ||
+    r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "file:///src/main.go" ||
+    r.Locations[0].PhysicalLocation.Region.StartColumn != 2 ||
+    len(r.RelatedLocations) != 1 {
+    t.Error("staticcheck result", r)
+  }
+
+  golang := log.Runs[1]
+  if len(golang.Results) != 2 || golang.Tool.Driver.Rules != nil {
+    t.Fatal("go run", golang)
+  }
+  fix := golang.Results[0].Fixes[0].ArtifactChanges[0]
+  if fix.ArtifactLocation.URI != "main.go" ||
+    *fix.Replacements[0].DeletedRegion.ByteOffset != 10 ||
+    *fix.Replacements[0].DeletedRegion.ByteLength != 1 {
+    t.Error("Fix", fix)
+  }
+  panic_result := golang.Results[1]
+  if panic_result.Locations != nil || len(panic_result.Stacks) != 1 ||
+    panic_result.Stacks[0].Frames[0].Location.LogicalLocations[0].
+      FullyQualifiedName != "main.main" {
+    t.Error("Panic", panic_result)
+  }
+}
+
+func TestExport(t *testing.T) {
+  name := t.TempDir() + "/out/coco.sarif"
+  if err := Export("sarif", name, []CompileLine{}); err != nil {
+    t.Fatal(err)
+  }
+  if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0644 {
+    t.Error("Expected export with mode 0644, got", info, err)
+  }
+  if err := Export("nope", name, []CompileLine{}); err == nil {
+    t.Error("Expected error for unknown format")
+  }
+}
@@ -0,0 +1,226 @@
+package backend
+
+import (
+  "go/build"
+  "path/filepath"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+type StackFrame struct {
+  Function string
+  File string
+  Line int
+  // Whether the frame is in the project's own code rather than a library
+  InProject bool
+}
+
+// rootedParser is implemented by parsers which can use the project root to
+// pick out the project's own frames from a stack trace.
+type rootedParser interface {
+  withRoot(root string) Parser
+}
+
+// panicParser reports Go panics, located at the first frame of the panicking
+// goroutine inside root. With no root, frames outside GOROOT and the module
+// cache are treated as the project's.
+type panicParser struct {
+  root string
+}
+
+var panicReg = regexp.MustCompile(`^(?:panic: |fatal error: )(.*?)(?: \[recovered.*\])?$`)
+var goroutineReg = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+var goFuncReg = regexp.MustCompile(`^(?:created by )?(\S.*?)(?:\([^()]*\))?(?: in goroutine \d+)?$`)
+var goFrameReg = regexp.MustCompile(`^\t(.+?):(\d+)(?: \+0x[0-9a-f]+)?$`)
+
+func NewPanicParser(root string) Parser {
+  return panicParser{root}
+}
+
+func (p panicParser) withRoot(root string) Parser {
+  return panicParser{root}
+}
+
+func (p panicParser) Parse(output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  lines := strings.Split(output, "\n")
+  for i := 0; i < len(lines); i++ {
+    match := panicReg.FindStringSubmatch(lines[i])
+    if match == nil {
+      continue
+    }
+    cl := CompileLine{Severity: SeverityError, Code: "panic", Tool: "go",
+      Message: "panic: " + match[1]}
+    if strings.HasPrefix(lines[i], "fatal error: ") {
+      cl.Code, cl.Message = "fatal", lines[i]
+    }
+    // Repanics print further indented panic lines before the dump
+    for i + 1 < len(lines) && (strings.TrimSpace(lines[i + 1]) == "" ||
+      strings.HasPrefix(lines[i + 1], "\tpanic: ")) {
+      i++
+    }
+    if i + 1 < len(lines) && goroutineReg.MatchString(lines[i + 1]) {
+      cl.Frames, i = p.frames(lines, i + 2)
+    }
+    locateFrames(&cl)
+    cls = append(cls, cl)
+  }
+  return cls
+}
+
+// frames reads function and file line pairs starting at lines[i], returning
+// them along with the index of the last line used.
+func (p panicParser) frames(lines []string, i int) ([]StackFrame, int) {
+  frames := make([]StackFrame, 0)
+  for ; i + 1 < len(lines); i += 2 {
+    fn := goFuncReg.FindStringSubmatch(lines[i])
+    loc := goFrameReg.FindStringSubmatch(lines[i + 1])
+    if fn == nil || loc == nil {
+      break
+    }
+    f := StackFrame{Function: fn[1], File: loc[1]}
+    f.Line, _ = strconv.Atoi(loc[2])
+    f.InProject = p.inProject(f)
+    frames = append(frames, f)
+  }
+  return frames, i - 1
+}
+
+func (p panicParser) inProject(f StackFrame) bool {
+  if p.root != "" {
+    root := strings.TrimSuffix(p.root, "/") + "/"
+    return strings.HasPrefix(f.File, root)
+  }
+  if strings.HasPrefix(f.Function, "runtime.") ||
+    strings.HasPrefix(f.Function, "testing.") {
+    return false
+  }
+  goroot := filepath.Join(build.Default.GOROOT, "src") + "/"
+  modcache := filepath.Join(build.Default.GOPATH, "pkg", "mod") + "/"
+  return !strings.HasPrefix(f.File, goroot) && !strings.HasPrefix(f.File, modcache)
+}
+
+// goParser reports compiler and vet style diagnostics along with any panics.
+type goParser struct {
+  diag *regexParser
+  panics panicParser
+}
+
+func newGoParser() goParser {
+  return goParser{newRegexParser("go", `^\s+\S`,
+    `^\s*(?P<file>[^\s:#][^:]*\.go):(?P<line>\d+)(?::(?P<col>\d+))?: (?P<message>.*)$`),
+    panicParser{}}
+}
+
+func (p goParser) Parse(output string) []CompileLine {
+  return append(p.diag.Parse(output), p.panics.Parse(output)...)
+}
+
+func (p goParser) withRoot(root string) Parser {
+  return goParser{p.diag, panicParser{root}}
+}
+
+// pythonParser reports one diagnostic per Python traceback, located at the
+// innermost frame inside root. With no root, frames outside the standard
+// library and installed packages are treated as the project's.
+type pythonParser struct {
+  root string
+}
+
+var pyFrameReg = regexp.MustCompile(`^\s+File "([^"]+)", line (\d+)(?:, in (.+))?$`)
+var pyLibReg = regexp.MustCompile(`/lib/python\d`)
+var pyExceptionReg = regexp.MustCompile(`^([A-Za-z_][\w.]*)(?:: (.*))?$`)
+
+func (p pythonParser) withRoot(root string) Parser {
+  return pythonParser{root}
+}
+
+func (p pythonParser) Parse(output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  frames := make([]StackFrame, 0)
+  for _, line := range strings.Split(output, "\n") {
+    if match := pyFrameReg.FindStringSubmatch(line); match != nil {
+      f := StackFrame{Function: match[3], File: match[1]}
+      f.Line, _ = strconv.Atoi(match[2])
+      f.InProject = p.inProject(f)
+      // Tracebacks list the most recent call last
+      frames = append([]StackFrame{f}, frames...)
+      continue
+    }
+    if len(frames) == 0 || line == "" || line[0] == ' ' || line[0] == '\t' ||
+      strings.HasPrefix(line, "Traceback ") {
+      continue
+    }
+    cl := CompileLine{Severity: SeverityError, Tool: "python",
+      Message: line, Frames: frames}
+    if match := pyExceptionReg.FindStringSubmatch(line); match != nil {
+      cl.Code = match[1]
+    }
+    locateFrames(&cl)
+    cls = append(cls, cl)
+    frames = make([]StackFrame, 0)
+  }
+  return cls
+}
+
+func (p pythonParser) inProject(f StackFrame) bool {
+  if p.root != "" {
+    return strings.HasPrefix(f.File, strings.TrimSuffix(p.root, "/") + "/")
+  }
+  return !strings.HasPrefix(f.File, "<") &&
+    !strings.Contains(f.File, "/site-packages/") &&
+    !strings.Contains(f.File, "/dist-packages/") &&
+    !pyLibReg.MatchString(f.File)
+}
+
+// jvmParser reports Java and Kotlin exceptions, one diagnostic for each
+// exception and each of its causes. Frames outside the JDK, Kotlin runtime
+// and test frameworks are treated as the project's.
+type jvmParser struct {}
+
+var jvmExceptionReg = regexp.MustCompile(
+  `^(?:Exception in thread "[^"]*" )?(?:Caused by: )?([\w$.]+(?:Exception|Error|Throwable)[\w$]*)(?:: (.*))?$`)
+var jvmFrameReg = regexp.MustCompile(`^\s+at (?:[\w.]+/)?([\w$.<>]+)\(([^():]+)(?::(\d+))?\)$`)
+var jvmLibraryPrefixes = []string{"java.", "javax.", "jdk.", "sun.", "com.sun.",
+  "kotlin.", "kotlinx.", "org.junit.", "junit.", "org.gradle.", "org.apache.maven."}
+
+func (p jvmParser) Parse(output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  for _, line := range strings.Split(output, "\n") {
+    if match := jvmExceptionReg.FindStringSubmatch(line); match != nil {
+      cl := CompileLine{Severity: SeverityError, Tool: "jvm", Code: match[1],
+        Message: match[1]}
+      if match[2] != "" {
+        cl.Message += ": " + match[2]
+      }
+      cls = append(cls, cl)
+    } else if match := jvmFrameReg.FindStringSubmatch(line); match != nil &&
+      len(cls) != 0 {
+      f := StackFrame{Function: match[1], File: match[2], InProject: true}
+      f.Line, _ = strconv.Atoi(match[3])
+      for _, v := range jvmLibraryPrefixes {
+        f.InProject = f.InProject && !strings.HasPrefix(f.Function, v)
+      }
+      cl := &cls[len(cls) - 1]
+      cl.Frames = append(cl.Frames, f)
+      locateFrames(cl)
+    }
+  }
+  return cls
+}
+
+// locateFrames points cl at its innermost project frame, or its innermost
+// frame if none are in the project.
+func locateFrames(cl *CompileLine) {
+  cl.FileName, cl.Line = "", 0
+  for _, f := range cl.Frames {
+    if f.InProject {
+      cl.FileName, cl.Line = f.File, f.Line
+      return
+    }
+  }
+  if len(cl.Frames) != 0 {
+    cl.FileName, cl.Line = cl.Frames[0].File, cl.Frames[0].Line
+  }
+}
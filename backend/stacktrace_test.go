@@ -0,0 +1,149 @@
+package backend
+
+import (
+  "testing"
+)
+
+var panic_out string = `--- FAIL: TestGet (0.00s)
+panic: runtime error: index out of range [5] with length 3 [recovered]
+	panic: runtime error: index out of range [5] with length 3
+
+goroutine 7 [running]:
+testing.tRunner.func1.2({0x5230e0, 0xc000016150})
+	/usr/local/go/src/testing/testing.go:1545 +0x238
+panic({0x5230e0?, 0xc000016150?})
+	/usr/local/go/src/runtime/panic.go:914 +0x21f
+example.com/store.(*Store).Get(...)
+	/home/dev/store/store.go:14
+example.com/store.TestGet(0x0?)
+	/home/dev/store/store_test.go:9 +0x1d
+testing.tRunner(0xc0000076c0, 0x54b9d0)
+	/usr/local/go/src/testing/testing.go:1595 +0xff
+created by testing.(*T).Run in goroutine 1
+	/usr/local/go/src/testing/testing.go:1648 +0x3ad
+exit status 2
+FAIL	example.com/store	0.005s
+`
+
+func TestPanicParser(t *testing.T) {
+  p, _ := GetParser("gopanic")
+  cls := p.Parse(panic_out)
+  t.Log(cls)
+  if len(cls) != 1 {
+    t.Fatal("Parsed", len(cls), "panics, expected 1")
+  }
+  cl := cls[0]
+  if cl.Message != "panic: runtime error: index out of range [5] with length 3" ||
+    cl.FileName != "/home/dev/store/store.go" || cl.Line != 14 {
+    t.Error("Panic", cl)
+  }
+  if len(cl.Frames) != 6 {
+    t.Fatal("Frames", cl.Frames)
+  }
+  if cl.Frames[2].Function != "example.com/store.(*Store).Get" ||
+    !cl.Frames[2].InProject || cl.Frames[0].InProject {
+    t.Error("Frames", cl.Frames)
+  }
+  if cl.Frames[5].Function != "testing.(*T).Run" {
+    t.Error("Created by frame", cl.Frames[5])
+  }
+}
+
+func TestPanicParserRoot(t *testing.T) {
+  p := NewPanicParser("/home/dev/store/store_test.go")
+  cls := p.Parse(panic_out)
+  if len(cls) != 1 || cls[0].Line != 1545 {
+    t.Error("Expected first frame without project frames", cls)
+  }
+
+  p = NewPanicParser("/home/dev")
+  cls = p.Parse(panic_out)
+  if len(cls) != 1 || cls[0].FileName != "/home/dev/store/store.go" {
+    t.Error("Expected store.go inside root", cls)
+  }
+}
+
+func TestGoParserPanic(t *testing.T) {
+  cls := Parse("main.go:3:1: bad\n" + panic_out, "")
+  t.Log(cls)
+  if len(cls) != 2 || cls[1].Code != "panic" {
+    t.Error("Expected compile error and panic", cls)
+  }
+}
+
+var python_chained_out string = `Traceback (most recent call last):
+  File "/home/dev/tool/cli.py", line 4, in load
+    return json.loads(text)
+  File "/usr/lib/python3.11/json/__init__.py", line 346, in loads
+    return _default_decoder.decode(s)
+json.decoder.JSONDecodeError: Expecting value: line 1 column 1 (char 0)
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "/home/dev/tool/cli.py", line 9, in <module>
+    load("")
+  File "/home/dev/tool/cli.py", line 6, in load
+    raise ValueError("bad config")
+ValueError: bad config
+  File "/home/dev/tool/gen.py", line 3
+    print("a"
+         ^
+SyntaxError: '(' was never closed
+`
+
+func TestPythonParser(t *testing.T) {
+  p, _ := GetParser("python")
+  cls := p.Parse(python_chained_out)
+  t.Log(cls)
+  if len(cls) != 3 {
+    t.Fatal("Parsed", len(cls), "exceptions, expected 3")
+  }
+  if cls[0].Code != "json.decoder.JSONDecodeError" ||
+    cls[0].FileName != "/home/dev/tool/cli.py" || cls[0].Line != 4 ||
+    len(cls[0].Frames) != 2 || cls[0].Frames[0].InProject {
+    t.Error("First exception", cls[0])
+  }
+  if cls[1].Message != "ValueError: bad config" || cls[1].Line != 6 ||
+    cls[1].Frames[0].Function != "load" || cls[1].Frames[1].Function != "<module>" {
+    t.Error("Second exception", cls[1])
+  }
+  if cls[2].Code != "SyntaxError" || cls[2].FileName != "/home/dev/tool/gen.py" ||
+    cls[2].Line != 3 {
+    t.Error("Syntax error", cls[2])
+  }
+
+  cls = pythonParser{}.withRoot("/home/dev/other").Parse(python_chained_out)
+  if cls[0].FileName != "/usr/lib/python3.11/json/__init__.py" {
+    t.Error("Expected innermost frame outside root", cls[0])
+  }
+}
+
+var jvm_out string = `Exception in thread "main" java.lang.IllegalStateException: could not start
+	at com.example.App.start(App.kt:21)
+	at com.example.App.main(App.kt:8)
+Caused by: java.io.FileNotFoundException: app.conf (No such file or directory)
+	at java.base/java.io.FileInputStream.open0(Native Method)
+	at java.base/java.io.FileInputStream.open(FileInputStream.java:216)
+	at com.example.Config.load(Config.java:40)
+	... 2 more
+`
+
+func TestJVMParser(t *testing.T) {
+  p, _ := GetParser("kotlin")
+  cls := p.Parse(jvm_out)
+  t.Log(cls)
+  if len(cls) != 2 {
+    t.Fatal("Parsed", len(cls), "exceptions, expected 2")
+  }
+  if cls[0].Code != "java.lang.IllegalStateException" ||
+    cls[0].Message != "java.lang.IllegalStateException: could not start" ||
+    cls[0].FileName != "App.kt" || cls[0].Line != 21 || len(cls[0].Frames) != 2 {
+    t.Error("First exception", cls[0])
+  }
+  if cls[1].FileName != "Config.java" || cls[1].Line != 40 ||
+    len(cls[1].Frames) != 3 || cls[1].Frames[0].InProject ||
+    cls[1].Frames[0].Function != "java.io.FileInputStream.open0" {
+    t.Error("Cause", cls[1])
+  }
+}
@@ -0,0 +1,172 @@
+package backend
+
+import (
+  "strings"
+  "time"
+)
+
+// LineScanner works through output a line at a time.
+type LineScanner interface {
+  // Line consumes one line of output, returning any diagnostics it completed.
+  Line(line string) []CompileLine
+  // Flush returns any diagnostic still being built at the end of output.
+  Flush() []CompileLine
+}
+
+// LineParser is implemented by parsers which can parse output incrementally.
+type LineParser interface {
+  Parser
+  NewScanner() LineScanner
+}
+
+func scanAll(s LineScanner, output string) []CompileLine {
+  cls := make([]CompileLine, 0)
+  for _, line := range strings.Split(output, "\n") {
+    cls = append(cls, s.Line(line)...)
+  }
+  return append(cls, s.Flush()...)
+}
+
+// Shortest time between reruns of parsers which are not LineParsers
+var streamReparseInterval = 250 * time.Millisecond
+
+// StreamParser parses command output as it is written, passing diagnostics to
+// emit as soon as they are complete. Parsers which are not LineParsers are
+// rerun over the output so far every so often as it is written, with their
+// last diagnostic held back until the output ends. With a resolver, make and
+// ninja directory changes are followed and file names resolved.
+type StreamParser struct {
+  parser Parser
+  resolver *PathResolver
+  emit func([]CompileLine)
+
+  scanner LineScanner
+  // Incomplete last line of the output so far
+  partial string
+  dirs []string
+
+  // Output from the current directory when there is no scanner, and how many
+  // of each of its diagnostics have been emitted. Parsers do not always list
+  // diagnostics in output order, so they are matched by key not position.
+  segment []string
+  emitted map[string]int
+  // Reruns wait for interval, or ten times as long as the last one took, so
+  // that long output is not parsed over and over. Zero reruns after every
+  // write, and a negative interval only parses once the output ends.
+  interval time.Duration
+  parsed time.Time
+  took time.Duration
+}
+
+func NewStreamParser(p Parser, r *PathResolver,
+  emit func([]CompileLine)) *StreamParser {
+  s := new(StreamParser)
+  s.parser = p
+  s.resolver = r
+  s.emit = emit
+  s.interval = streamReparseInterval
+  if lp, ok := p.(LineParser); ok {
+    s.scanner = lp.NewScanner()
+  }
+  if r != nil {
+    s.dirs = []string{r.Dir}
+  }
+  return s
+}
+
+func (s *StreamParser) Write(b []byte) (int, error) {
+  lines := strings.Split(s.partial + string(b), "\n")
+  s.partial = lines[len(lines) - 1]
+  for _, line := range lines[:len(lines) - 1] {
+    s.line(line)
+  }
+  if s.scanner == nil && s.due() {
+    s.reparse(false)
+  }
+  return len(b), nil
+}
+
+// Close parses any remaining output and emits the diagnostics held back.
+func (s *StreamParser) Close() error {
+  if s.partial != "" {
+    s.line(s.partial)
+    s.partial = ""
+  }
+  s.endSegment()
+  return nil
+}
+
+func (s *StreamParser) line(line string) {
+  if s.resolver != nil {
+    if match := enterDirReg.FindStringSubmatch(line); match != nil {
+      s.endSegment()
+      s.dirs = append(s.dirs, s.resolver.join(s.dirs[len(s.dirs) - 1], match[1]))
+      return
+    } else if leaveDirReg.MatchString(line) {
+      s.endSegment()
+      if len(s.dirs) > 1 {
+        s.dirs = s.dirs[:len(s.dirs) - 1]
+      }
+      return
+    }
+  }
+  if s.scanner != nil {
+    s.send(s.scanner.Line(line))
+  } else {
+    s.segment = append(s.segment, line)
+  }
+}
+
+func (s *StreamParser) endSegment() {
+  if s.scanner != nil {
+    s.send(s.scanner.Flush())
+    return
+  }
+  s.reparse(true)
+  s.segment = nil
+  s.emitted = nil
+}
+
+// due reports whether enough time has passed to rerun the parser.
+func (s *StreamParser) due() bool {
+  since := time.Since(s.parsed)
+  return s.interval == 0 ||
+    s.interval > 0 && since >= s.interval && since >= 10 * s.took
+}
+
+func (s *StreamParser) reparse(final bool) {
+  if len(s.segment) == 0 {
+    return
+  }
+  start := time.Now()
+  cls := s.parser.Parse(strings.Join(s.segment, "\n"))
+  s.parsed, s.took = time.Now(), time.Since(start)
+  // The last diagnostic may still gain lines until the segment ends
+  if !final && len(cls) != 0 {
+    cls = cls[:len(cls) - 1]
+  }
+  if s.emitted == nil {
+    s.emitted = map[string]int{}
+  }
+  seen := map[string]int{}
+  unsent := make([]CompileLine, 0)
+  for _, cl := range cls {
+    key := diffKey(cl)
+    seen[key]++
+    if seen[key] > s.emitted[key] {
+      unsent = append(unsent, cl)
+      s.emitted[key]++
+    }
+  }
+  s.send(unsent)
+}
+
+func (s *StreamParser) send(cls []CompileLine) {
+  if len(cls) == 0 {
+    return
+  }
+  if s.resolver != nil {
+    cls = s.resolver.resolveAll(cls, s.dirs[len(s.dirs) - 1])
+  }
+  s.emit(cls)
+}
@@ -0,0 +1,160 @@
+package backend
+
+import (
+  "testing"
+  "os/exec"
+)
+
+func TestStreamParserScanner(t *testing.T) {
+  found := make([]CompileLine, 0)
+  s := NewStreamParser(parsers["gcc"], nil, func(cls []CompileLine) {
+    found = append(found, cls...)
+  })
+
+  s.Write([]byte("a.c:1:1: error: first\n    1 | int x\n"))
+  if len(found) != 0 {
+    t.Error("Emitted", found, "before the diagnostic was complete")
+  }
+  s.Write([]byte("a.c:2:1: note: related\nb.c:3:"))
+  if len(found) != 0 {
+    t.Error("Emitted", found, "before notes were complete")
+  }
+  s.Write([]byte("1: error: second\n"))
+  if len(found) != 1 || found[0].Line != 1 || len(found[0].Related) != 1 {
+    t.Fatal("Expected first diagnostic with its note, got", found)
+  }
+  s.Close()
+  if len(found) != 2 || found[1].FileName != "b.c" {
+    t.Error("Expected second diagnostic on close, got", found)
+  }
+}
+
+func TestStreamParserReparse(t *testing.T) {
+  found := make([]CompileLine, 0)
+  p, _ := GetParser("rustc")
+  s := NewStreamParser(p, nil, func(cls []CompileLine) {
+    found = append(found, cls...)
+  })
+  s.interval = 0
+
+  s.Write([]byte(rust_out))
+  if len(found) != 0 {
+    t.Error("Emitted", found, "before output ended")
+  }
+  s.Write([]byte(rust_out))
+  if len(found) != 1 {
+    t.Error("Expected first of two diagnostics, got", found)
+  }
+  s.Close()
+  if len(found) != 2 {
+    t.Error("Expected both diagnostics on close, got", found)
+  }
+}
+
+func TestStreamParserReorder(t *testing.T) {
+  found := make([]CompileLine, 0)
+  s := NewStreamParser(parsers["go"], nil, func(cls []CompileLine) {
+    found = append(found, cls...)
+  })
+  s.interval = 0
+
+  // The go parser lists panics after every other diagnostic, so output
+  // written later can come first
+  for _, out := range []string{
+    "panic: first\n\ngoroutine 1 [running]:\nmain.f()\n\t/src/a.go:5 +0x1d\n\n",
+    "panic: second\n\ngoroutine 1 [running]:\nmain.g()\n\t/src/a.go:9 +0x1d\n\n",
+    "    b_test.go:20: later failure\n",
+    "ok\n",
+  } {
+    s.Write([]byte(out))
+  }
+  s.Close()
+  messages := make([]string, 0, len(found))
+  for _, cl := range found {
+    messages = append(messages, cl.Message)
+  }
+  t.Log(messages)
+  if len(found) != 3 {
+    t.Fatal("Expected each diagnostic once, got", messages)
+  }
+  seen := map[string]bool{}
+  for _, m := range messages {
+    seen[m] = true
+  }
+  if !seen["panic: first"] || !seen["panic: second"] ||
+    !seen["later failure"] {
+    t.Error("Expected both panics and the test failure, got", messages)
+  }
+}
+
+func TestStreamParserDirs(t *testing.T) {
+  found := make([]CompileLine, 0)
+  s := NewStreamParser(parsers["gcc"], NewPathResolver("/project", "/project"),
+    func(cls []CompileLine) {
+      found = append(found, cls...)
+    })
+  s.Write([]byte("make: Entering directory '/project/sub'\nx.c:1:1: error: bad\n"))
+  s.Write([]byte("make: Leaving directory '/project/sub'\n"))
+  if len(found) != 1 || found[0].FileName != "/project/sub/x.c" {
+    t.Error("Expected diagnostic when leaving directory, got", found)
+  }
+  s.Close()
+}
+
+func TestRoutineStream(t *testing.T) {
+  found := make([]CompileLine, 0)
+  c := exec.Command("sh", "-c", "echo 'a.c:1:1: error: out'; sleep 0.1; echo 'b.c:2:1: error: err' >&2")
+  s := NewStreamParser(parsers["gcc"], nil, func(cls []CompileLine) {
+    found = append(found, cls...)
+  })
+  out_chan := make(chan RoutineOut)
+  go RunRoutine(out_chan, c)
+  output, err := RoutineResult(out_chan, func(chunk *CommandOutput) {
+    s.Write([]byte(chunk.String()))
+  })
+  res_string := output.String()
+  t.Log(res_string)
+  if err != nil || res_string != "a.c:1:1: error: out\nb.c:2:1: error: err\n" {
+    t.Error("Routine output", res_string, err)
+  }
+  if len(found) != 1 || found[0].FileName != "a.c" {
+    t.Error("Streamed", found, "expected first diagnostic before close")
+  }
+  s.Close()
+  if len(found) != 2 {
+    t.Error("Streamed", found, "expected both diagnostics after close")
+  }
+}
+
+type countingParser struct {
+  calls *int
+}
+
+func (p countingParser) Parse(output string) []CompileLine {
+  *p.calls++
+  return parsers["go"].Parse(output)
+}
+
+func TestStreamParserThrottle(t *testing.T) {
+  calls := 0
+  found := make([]CompileLine, 0)
+  s := NewStreamParser(countingParser{&calls}, nil, func(cls []CompileLine) {
+    found = append(found, cls...)
+  })
+  for i := 0; i != 1000; i++ {
+    s.Write([]byte("./a.go:1:2: undefined: x\n"))
+  }
+  if calls > 10 {
+    t.Error("Parsed", calls, "times for 1000 writes")
+  }
+  s.Close()
+  if len(found) != 1000 {
+    t.Error("Found", len(found), "diagnostics, expected 1000")
+  }
+
+  calls = 0
+  NewPathResolver("", "").Parse(countingParser{&calls}, "./a.go:1:2: undefined: x\n")
+  if calls != 1 {
+    t.Error("Parsed complete output", calls, "times, expected once")
+  }
+}
@@ -4,10 +4,15 @@ import (
   "github.com/jroimartin/gocui"
   "github.com/MikeKneeB/coco/frontend"
   "log"
+  "os"
 )
 
 func main() {
   c := frontend.NewController()
+  if c.Headless() {
+    runOnce(c)
+    return
+  }
   g, err := gocui.NewGui(gocui.OutputNormal)
   if err != nil {
     log.Panicln(err)
@@ -41,3 +46,25 @@ func main() {
     log.Panicln(err)
   }
 }
+
+// runOnce runs the commands once without the gui, exiting with 1 if they
+// found any problems.
+func runOnce(c *frontend.Controller) {
+  err := c.ReadConfig()
+  if err != nil {
+    log.Fatalln(err)
+  }
+
+  err = c.Init()
+  if err != nil {
+    log.Fatalln(err)
+  }
+
+  clean, err := c.RunOnce()
+  if err != nil {
+    log.Fatalln(err)
+  }
+  if !clean {
+    os.Exit(1)
+  }
+}
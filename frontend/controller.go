@@ -9,6 +9,7 @@ import (
   "os"
   "time"
   "flag"
+  "strings"
 )
 
 var ErrNoConfig = errors.New("Config file not yet read!")
@@ -21,6 +22,27 @@ type Controller struct {
   Gui *gocui.Gui
 
   runner Runner
+  // One pane for each pipeline, and the pane being shown
+  panes []*pane
+  current int
+  // Resolves names in reports, which do not belong to any one step
+  resolver *backend.PathResolver
+  // Files to write diagnostics to after each run, by format
+  exports map[string]string
+  minSeverity backend.Severity
+  showRelated bool
+  // Show the coverage report instead of diagnostics
+  coverView bool
+  // Prefix raw output lines with the time they were written
+  showTimes bool
+
+  // Failures from JUnit reports, which belong to no one pane, and how they
+  // differ from the run before
+  reports []backend.CompileLine
+  reportDiff *backend.DiagnosticDiff
+  // Coverage from Reports.coverage and its change since the last report
+  coverage *backend.CoverageReport
+  coverageDelta *backend.CoverageChange
 
   operation string
   logY int
@@ -57,6 +79,26 @@ func (c *Controller) AddGui(g *gocui.Gui) error {
     return err
   }
 
+  err = g.SetKeybinding("", 'r', gocui.ModNone, c.toggleRelated)
+  if err != nil {
+    return err
+  }
+
+  err = g.SetKeybinding("", 'c', gocui.ModNone, c.toggleCoverage)
+  if err != nil {
+    return err
+  }
+
+  err = g.SetKeybinding("", 't', gocui.ModNone, c.toggleTimes)
+  if err != nil {
+    return err
+  }
+
+  err = g.SetKeybinding("", gocui.KeyTab, gocui.ModNone, c.nextPane)
+  if err != nil {
+    return err
+  }
+
   err = g.SetKeybinding("", 'j', gocui.ModNone, c.scrollDown)
   if err != nil {
     return err
@@ -85,6 +127,25 @@ func (c *Controller) toggleLog(g *gocui.Gui, v *gocui.View) error {
   return nil
 }
 
+func (c *Controller) toggleRelated(g *gocui.Gui, v *gocui.View) error {
+  c.showRelated = !c.showRelated
+  return c.renderOutput(g)
+}
+
+func (c *Controller) toggleCoverage(g *gocui.Gui, v *gocui.View) error {
+  if c.coverage == nil {
+    c.Log("No coverage report, set Reports.coverage")
+    return nil
+  }
+  c.coverView = !c.coverView
+  return c.renderOutput(g)
+}
+
+func (c *Controller) toggleTimes(g *gocui.Gui, v *gocui.View) error {
+  c.showTimes = !c.showTimes
+  return c.renderOutput(g)
+}
+
 func (c *Controller) scrollDown(g *gocui.Gui, v *gocui.View) error {
   v.MoveCursor(0, 1, false)
   return nil
@@ -167,13 +228,18 @@ func (c *Controller) Init() error {
     }
   }
 
-  if c.Configuration.IsSet("Init.Command") {
-    com := c.Configuration.GetString("Init.command")
-    args := c.Configuration.GetStringSlice("Init.args")
+  if c.Configuration.IsSet("Init.Command") ||
+    c.Configuration.IsSet("Init.shell") {
+    com, args, err := backend.ReadCommandLine(c.Configuration, "Init")
+    if err != nil {
+      return err
+    }
     dir := c.Configuration.GetString("Init.dir")
     env := c.Configuration.GetStringSlice("Init.env")
-    c.Log("Running init command:", com, args)
-    err = backend.RunInit(com, dir, args, env)
+    c.Log("Running init command: ", backend.CommandDef{Name: com, Args: args})
+    err = backend.RunInitTimeout(com, dir, args, env,
+      backend.ReadTimeout(c.Configuration, "Init"),
+      backend.ReadGracePeriod(c.Configuration))
     if err != nil {
       return err
     }
@@ -182,24 +248,54 @@ func (c *Controller) Init() error {
   return nil
 }
 
+// readSettings reads the pipelines to run, with a pane for each, along with
+// how their output is parsed and exported.
+func (c *Controller) readSettings() ([]backend.Pipeline, runLimits, error) {
+  limits := NewRunLimits(backend.ReadGracePeriod(c.Configuration))
+  pipelines, err := backend.ReadPipelines(c.Configuration)
+  if err != nil {
+    return nil, limits, err
+  }
+  for _, p := range pipelines {
+    c.panes = append(c.panes, newPane(p))
+  }
+  c.resolver = backend.ReadPathResolver(c.Configuration)
+  c.exports, err = backend.ReadExports(c.Configuration)
+  if err != nil {
+    return nil, limits, err
+  }
+  c.minSeverity, err = backend.ReadMinSeverity(c.Configuration)
+  return pipelines, limits, err
+}
+
 func (c *Controller) StartCommandLoop() error {
   if (c.Configuration == nil) {
     return ErrNoConfig
   }
 
-  com, err := backend.ReadPeriodicCommand(c.Configuration)
+  pipelines, limits, err := c.readSettings()
   if err != nil {
     return err
   }
+  for format, name := range c.exports {
+    if name == "-" {
+      c.Log("Export ", format, " to stdout is only written with -once")
+    }
+  }
 
   switch mode := backend.GetCommandMode(c.Configuration) ; mode {
   case backend.TimeMode:
-    c.runner = NewTimeRunner(c.Configuration.GetFloat64("RunOn.time"), com,
-    NewRunnerFuncs(c.ShowOutput, c.Log, c.UpdateOperation))
+    c.runner = NewTimeRunner(c.Configuration.GetFloat64("RunOn.time"), limits,
+    pipelines, NewRunnerFuncs(c.ShowOutput, c.Log, c.UpdateOperation, c.startStream))
   case backend.FSMode:
+    on_change, err := backend.ReadChangePolicy(c.Configuration)
+    if err != nil {
+      return err
+    }
     c.runner, err = NewFSRunner(c.Configuration.GetString("RunOn.fs_root"),
-    c.Configuration.GetStringSlice("RunOn.fs_extensions"), com,
-    NewRunnerFuncs( c.ShowOutput, c.Log, c.UpdateOperation))
+    c.Configuration.GetStringSlice("RunOn.fs_extensions"), on_change, limits,
+    pipelines,
+    NewRunnerFuncs(c.ShowOutput, c.Log, c.UpdateOperation, c.startStream))
     if err != nil {
       return err
     }
@@ -216,26 +312,436 @@ func (c *Controller) StopCommandLoop() {
   c.runner.Signal(Quit)
 }
 
-func (c *Controller) ShowOutput(output string, return_code int) {
+// ShowOutput shows the results of a run, reading any reports it wrote and
+// exporting the diagnostics of every pane along with them.
+func (c *Controller) ShowOutput(all [][]backend.StepResult, started time.Time) {
+  diagnostics := c.parseResults(all)
+  reports := c.readReports(started)
+  coverage := c.readCoverage(started)
+  c.Gui.Update(func(g *gocui.Gui) error {
+    if coverage != nil {
+      c.coverageDelta = backend.CoverageDelta(c.coverage, coverage)
+      c.coverage = coverage
+      total := ""
+      if c.coverageDelta != nil {
+        total = formatDelta(c.coverageDelta.Total)
+      }
+      c.Log(fmt.Sprintf("Coverage: %.1f%%%s", coverage.Total().Percent(),
+        total))
+    }
+    export := make([]backend.CompileLine, 0)
+    for p, pn := range c.panes {
+      cls := joinDiagnostics(diagnostics[p])
+      if pn.diagnostics != nil {
+        diff := backend.DiffDiagnostics(pn.diagnostics, cls)
+        pn.diff = &diff
+        if pn.pipeline.Name != "" {
+          c.Log(pn.pipeline.Name, " diagnostics: ", diff)
+        } else {
+          c.Log("Diagnostics: ", diff)
+        }
+      }
+      pn.results = all[p]
+      pn.diagnostics = cls
+      pn.steps = diagnostics[p]
+      pn.finish(started)
+      export = append(export, pn.diagnostics...)
+    }
+    if c.reports != nil {
+      diff := backend.DiffDiagnostics(c.reports, reports)
+      c.reportDiff = &diff
+      c.Log("Report diagnostics: ", diff)
+    }
+    c.reports = reports
+    c.export(append(export, reports...))
+    return c.renderOutput(g)
+  })
+}
 
+// parseResults finds the diagnostics in the results of each step of each
+// pipeline.
+func (c *Controller) parseResults(
+  all [][]backend.StepResult) [][][]backend.CompileLine {
+  diagnostics := make([][][]backend.CompileLine, len(all))
+  for p, results := range all {
+    diagnostics[p] = make([][]backend.CompileLine, len(results))
+    for i, result := range results {
+      if result.Output != nil {
+        step := c.panes[p].pipeline.Steps[i]
+        diagnostics[p][i] = step.Resolver.Parse(step.Parser,
+          result.Output.Text(step.Stream))
+      }
+    }
+  }
+  return diagnostics
+}
+
+// joinDiagnostics joins the diagnostics found by each step.
+func joinDiagnostics(steps [][]backend.CompileLine) []backend.CompileLine {
+  cls := make([]backend.CompileLine, 0)
+  for _, step := range steps {
+    cls = append(cls, step...)
+  }
+  return cls
+}
+
+// liveOutput appends a step's output to its pane as it is written, showing
+// the diagnostics found so far above it. Lines are added in order by the
+// runner, and the gui only redraws.
+type liveOutput struct {
+  c *Controller
+  pane *pane
+  parser *backend.StreamParser
+  stream backend.OutputStream
+  op string
+  // Steps of a graph run at once, so their lines are marked with the step
+  prefix string
+}
+
+func (l *liveOutput) Lines(lines []backend.OutputLine) {
+  live := make([]liveLine, 0, len(lines))
+  for _, line := range lines {
+    live = append(live, liveLine{prefix: l.prefix, line: line})
+  }
+  if !l.pane.addLines(live...) {
+    return
+  }
+  l.c.redraw(l.pane)
+  for _, line := range lines {
+    if l.stream == backend.StreamCombined || line.Stream == l.stream {
+      l.parser.Write([]byte(line.Text))
+    }
+  }
+}
+
+func (l *liveOutput) Close() error {
+  return l.parser.Close()
+}
+
+// startStream shows the output of a step as it runs, clearing its pane when
+// the first step of a new run starts. Steps of a graph are interleaved, so
+// each line is marked with its step instead of a header.
+func (c *Controller) startStream(pipeline, step int,
+  started time.Time) LiveOutput {
+  pn := c.panes[pipeline]
+  pn.start(started)
+  if len(pn.pipeline.Steps) != 1 && !pn.pipeline.Graph() {
+    pn.addLines(liveLine{header: fmt.Sprintf("\033[1m== %s ==\033[0m",
+      pn.pipeline.Steps[step].Name)})
+  }
+  c.redraw(pn)
+  l := &liveOutput{c: c, pane: pn, stream: pn.pipeline.Steps[step].Stream,
+    op: executingOp(pn.pipeline, step)}
+  if pn.pipeline.Graph() {
+    l.prefix = fmt.Sprintf("\033[1m[%s]\033[0m ", pn.pipeline.Steps[step].Name)
+  }
+  l.parser = backend.NewStreamParser(pn.pipeline.Steps[step].Parser,
+    pn.pipeline.Steps[step].Resolver, func(cls []backend.CompileLine) {
+      found := backend.FilterSeverity(cls, c.minSeverity)
+      if len(found) == 0 {
+        return
+      }
+      n := pn.addFound(found)
+      if n == -1 {
+        return
+      }
+      // Several panes running at once share the operation bar, so show
+      // counts in their tabs instead, as do graphs' running steps
+      if len(c.panes) == 1 && !pn.pipeline.Graph() {
+        c.operation = fmt.Sprintf("%s (%d found)", l.op, n)
+      }
+      c.redraw(pn)
+    })
+  return l
+}
+
+// redraw draws pn once the gui is free, or just its tab if it is not being
+// shown. Output can arrive far faster than it can be drawn, so a redraw
+// asked for while one is waiting is dropped.
+func (c *Controller) redraw(pn *pane) {
+  pn.mutex.Lock()
+  waiting := pn.redrawing
+  pn.redrawing = true
+  pn.mutex.Unlock()
+  if waiting {
+    return
+  }
   c.Gui.Update(func(g *gocui.Gui) error {
-    v, err := g.View("normal")
-    if err != nil {
-      return err
+    pn.mutex.Lock()
+    pn.redrawing = false
+    pn.mutex.Unlock()
+    if c.panes[c.current] != pn {
+      return c.setTitle(g)
     }
-    v.Clear()
-    if return_code == 0 {
-      v.Highlight = false
-      fmt.Fprint(v, "\033[32;1mLooks good!\033[0m")
-    } else {
-      v.Highlight = true
-      fmt.Fprintf(v, output)
+    return c.renderOutput(g)
+  })
+}
+
+// renderOutput draws the current pane into the normal view, must be called
+// from the gui goroutine.
+func (c *Controller) renderOutput(g *gocui.Gui) error {
+  v, err := g.View("normal")
+  if err != nil {
+    return err
+  }
+  pn := c.panes[c.current]
+  v.Clear()
+  v.Title = paneTitle(c.panes, c.current)
+  if running, live, found := pn.progress(); running {
+    // Keep the first diagnostics found in view above the output, rather than
+    // following the output as it grows
+    v.Highlight = len(found) != 0
+    v.Autoscroll = len(found) == 0
+    if !v.Autoscroll {
+      v.SetOrigin(0, 0)
+    }
+    for _, cl := range found {
+      c.printCompileLine(v, nil, cl)
+    }
+    if len(found) != 0 {
+      fmt.Fprintln(v, "\033[1m== output ==\033[0m")
+    }
+    for _, line := range live {
+      if line.header != "" {
+        fmt.Fprintln(v, line.header)
+      } else {
+        fmt.Fprintln(v, line.prefix + c.formatOutputLine(line.line))
+      }
     }
     return nil
-  })
+  }
+  v.Autoscroll = false
+  v.SetOrigin(0, 0)
+  v.SetCursor(0, 0)
+  c.renderSteps(v, pn)
+  if c.coverView && c.coverage != nil {
+    v.Highlight = false
+    renderCoverage(v, c.coverage, c.coverageDelta)
+    return nil
+  }
+  if pn.testStep != -1 && pn.testStep < len(pn.results) {
+    if report, err := backend.ParseGoTestJSON(
+      pn.results[pn.testStep].Output.Text(
+        pn.pipeline.Steps[pn.testStep].Stream)); err == nil {
+      // Other steps may find problems without failing, list them first
+      others := backend.FilterSeverity(pn.otherDiagnostics(), c.minSeverity)
+      v.Highlight = report.Failed() || len(others) != 0
+      if len(others) != 0 {
+        c.printDiagnostics(v, others, pn.diff)
+        fmt.Fprintln(v, "\033[1m== tests ==\033[0m")
+      }
+      renderTestReport(v, report)
+      c.printReports(v)
+      return nil
+    }
+  }
+  if pn.results == nil {
+    v.Highlight = false
+    return nil
+  }
+  shown := backend.FilterSeverity(pn.diagnostics, c.minSeverity)
+  clean := backend.FailedStep(pn.results) == nil && len(shown) == 0
+  if clean && len(c.reports) == 0 {
+    v.Highlight = false
+    fmt.Fprint(v, "\033[32;1mLooks good!\033[0m")
+    fixed := 0
+    for _, diff := range []*backend.DiagnosticDiff{pn.diff, c.reportDiff} {
+      if diff != nil {
+        fixed += len(diff.Fixed)
+      }
+    }
+    if fixed != 0 {
+      fmt.Fprintf(v, " (%d fixed)", fixed)
+    }
+    return nil
+  }
+  v.Highlight = true
+  // A failing run with nothing severe enough to show falls back to its output
+  if len(shown) != 0 {
+    c.printDiagnostics(v, pn.diagnostics, pn.diff)
+  } else if !clean {
+    for _, result := range pn.results {
+      if result.Output == nil {
+        continue
+      }
+      if len(pn.results) != 1 {
+        fmt.Fprintf(v, "\033[1m== %s ==\033[0m\n", result.Name)
+      }
+      for _, line := range result.Output.Lines {
+        fmt.Fprintln(v, c.formatOutputLine(line))
+      }
+    }
+  }
+  c.printReports(v)
+  return nil
+}
+
+// printReports lists the diagnostics from reports under their own heading.
+func (c *Controller) printReports(v *gocui.View) {
+  if len(c.reports) != 0 {
+    fmt.Fprintln(v, "\033[1m== reports ==\033[0m")
+    c.printDiagnostics(v, c.reports, c.reportDiff)
+  }
+}
+
+// printDiagnostics lists cls, worst first, after how they differ from the run
+// before.
+func (c *Controller) printDiagnostics(v *gocui.View, cls []backend.CompileLine,
+  diff *backend.DiagnosticDiff) {
+  if diff != nil {
+    fmt.Fprintf(v, "\033[1m%s\033[0m\n", diff)
+  }
+  cls = backend.FilterSeverity(cls, c.minSeverity)
+  backend.SortBySeverity(cls)
+  for _, cl := range cls {
+    c.printCompileLine(v, diff, cl)
+  }
+}
+
+// renderSteps shows how each step of a pipeline went on one line, along with
+// any step which timed out or could not be run.
+func (c *Controller) renderSteps(v *gocui.View, pn *pane) {
+  summary := make([]string, 0, len(pn.results))
+  for _, result := range pn.results {
+    switch {
+    case result.Skipped:
+      summary = append(summary, "\033[2m" + result.Name + " skipped\033[0m")
+    case result.Failed():
+      summary = append(summary, fmt.Sprintf("\033[31;1m%s failed (%d)\033[0m",
+        result.Name, result.ReturnCode))
+    default:
+      summary = append(summary, "\033[32m" + result.Name + " ok\033[0m")
+    }
+  }
+  if len(pn.results) > 1 {
+    fmt.Fprintln(v, strings.Join(summary, " | "))
+  }
+  for _, result := range pn.results {
+    if result.Err != nil {
+      fmt.Fprintf(v, "\033[31;1m%s: %s\033[0m\n", result.Name, result.Err)
+    } else if result.Output != nil && result.Output.Timeout != 0 {
+      fmt.Fprintf(v, "\033[31;1m%s timed out after %s\033[0m\n", result.Name,
+        result.Output.Timeout)
+    }
+  }
+}
+
+// formatOutputLine formats a line of raw output, with stderr in red.
+func (c *Controller) formatOutputLine(line backend.OutputLine) string {
+  text := strings.TrimSuffix(line.Text, "\n")
+  if line.Stream == backend.StreamStderr {
+    text = "\033[31m" + text + "\033[0m"
+  }
+  if c.showTimes {
+    text = line.Time.Format("15:04:05.000 ") + text
+  }
+  return text
+}
+
+// readReports collects failures from any JUnit reports configured with
+// Reports.junit which were written since the run started.
+func (c *Controller) readReports(started time.Time) []backend.CompileLine {
+  globs := c.Configuration.GetStringSlice("Reports.junit")
+  if len(globs) == 0 {
+    return nil
+  }
+  cls, err := backend.ReadJUnitReports(
+    c.Configuration.GetString("PeriodicCommand.dir"), globs, started)
+  if cls == nil {
+    c.Log("Error reading JUnit reports: ", err)
+    return nil
+  }
+  if err != nil {
+    c.Log(err)
+  }
+  return c.resolver.Resolve(cls)
+}
+
+// export writes diagnostics to each of the files configured under Export.
+// Exports to stdout are left out while the gui is drawing there.
+func (c *Controller) export(diagnostics []backend.CompileLine) {
+  for format, name := range c.exports {
+    if name == "-" && c.Gui != nil {
+      continue
+    }
+    if err := backend.Export(format, name, diagnostics); err != nil {
+      c.Log("Error exporting ", format, ": ", err)
+    }
+  }
+}
+
+// readCoverage reads the coverprofile or lcov file configured with
+// Reports.coverage, if the last run wrote one.
+func (c *Controller) readCoverage(started time.Time) *backend.CoverageReport {
+  name := c.Configuration.GetString("Reports.coverage")
+  if name == "" {
+    return nil
+  }
+  report, err := backend.ReadCoverage(
+    c.Configuration.GetString("PeriodicCommand.dir"), name, started)
+  if err != nil {
+    c.Log("Error reading coverage: ", err)
+    return nil
+  }
+  return report
+}
+
+func (c *Controller) printCompileLine(v *gocui.View,
+  diff *backend.DiagnosticDiff, cl backend.CompileLine) {
+  if diff != nil && diff.IsNew(cl) {
+    fmt.Fprint(v, "\033[7mNEW\033[0m ")
+  }
+  related := len(cl.Related) + len(cl.Frames) + len(cl.Fixes)
+  if !c.showRelated && related != 0 {
+    fmt.Fprintf(v, "%s (+%d related)\n", formatCompileLine(cl), related)
+    return
+  }
+  fmt.Fprintln(v, formatCompileLine(cl))
+  if c.showRelated {
+    for _, r := range cl.Related {
+      fmt.Fprintln(v, "    " + formatCompileLine(r))
+    }
+    for _, f := range cl.Frames {
+      marker := " "
+      if f.InProject {
+        marker = "*"
+      }
+      fmt.Fprintf(v, "  %s at %s %s:%d\n", marker, f.Function, f.File, f.Line)
+    }
+    for _, f := range cl.Fixes {
+      fmt.Fprintln(v, "    fix: " + f.Message)
+    }
+  }
+}
+
+var severityColours = map[backend.Severity]string{
+  backend.SeverityError: "\033[31;1m",
+  backend.SeverityWarning: "\033[33;1m",
+  backend.SeverityNote: "\033[36m",
+}
+
+func formatCompileLine(cl backend.CompileLine) string {
+  pos := cl.FileName
+  if cl.Line != 0 {
+    pos += fmt.Sprintf(":%d", cl.Line)
+  }
+  if cl.Line != 0 && cl.Column != 0 {
+    pos += fmt.Sprintf(":%d", cl.Column)
+  }
+  line := fmt.Sprintf("%s: %s%s\033[0m: %s", pos, severityColours[cl.Severity],
+    cl.Severity, cl.Message)
+  if cl.Code != "" {
+    line += " [" + cl.Code + "]"
+  }
+  return line
 }
 
 func (c *Controller) Log(items ...interface{}) {
+  if c.Gui == nil {
+    fmt.Fprintln(os.Stderr, time.Now().Format("2006/01/02 15:04:05 ") +
+      fmt.Sprint(items...))
+    return
+  }
   c.Gui.Update(func(g *gocui.Gui) error {
     v, err := g.View("log")
     if err != nil {
@@ -0,0 +1,56 @@
+package frontend
+
+import (
+  "github.com/jroimartin/gocui"
+  "github.com/MikeKneeB/coco/backend"
+  "fmt"
+)
+
+// coverageColour picks green for well covered code and red for poorly covered.
+func coverageColour(percent float64) string {
+  switch {
+  case percent >= 80:
+    return "\033[32m"
+  case percent >= 50:
+    return "\033[33m"
+  default:
+    return "\033[31m"
+  }
+}
+
+func formatDelta(d float64) string {
+  switch {
+  case d > 0.05:
+    return fmt.Sprintf(" \033[32m+%.1f%%\033[0m", d)
+  case d < -0.05:
+    return fmt.Sprintf(" \033[31m%.1f%%\033[0m", d)
+  }
+  return ""
+}
+
+func renderCoverageLine(v *gocui.View, indent string, f backend.FileCoverage,
+  delta float64) {
+  fmt.Fprintf(v, "%s%s%5.1f%%\033[0m %s (%d/%d)%s\n", indent,
+    coverageColour(f.Percent()), f.Percent(), f.Name, f.Covered, f.Statements,
+    formatDelta(delta))
+}
+
+// renderCoverage draws the total and per-package coverage, with each package's
+// files beneath it, along with the change since the previous report.
+func renderCoverage(v *gocui.View, report *backend.CoverageReport,
+  change *backend.CoverageChange) {
+  total, delta := 0.0, map[string]float64{}
+  if change != nil {
+    total, delta = change.Total, change.Names
+  }
+  fmt.Fprint(v, "\033[1m")
+  renderCoverageLine(v, "", report.Total(), total)
+  for _, pkg := range report.Packages() {
+    renderCoverageLine(v, "", pkg, delta[pkg.Name])
+    for _, f := range report.Files {
+      if backend.CoveragePackage(f.Name) == pkg.Name {
+        renderCoverageLine(v, "  ", f, delta[f.Name])
+      }
+    }
+  }
+}
@@ -0,0 +1,61 @@
+package frontend
+
+import (
+  "github.com/MikeKneeB/coco/backend"
+  "context"
+  "flag"
+  "time"
+)
+
+var run_once = flag.Bool("once", false, "Run the commands once without the UI, writing exports named - to stdout")
+
+// Headless reports whether coco was asked to run once without the gui, for
+// use in CI.
+func (c *Controller) Headless() bool {
+  return *run_once
+}
+
+// RunOnce runs every pipeline a single time without the gui, then reads
+// reports and writes the exports. When no export goes to stdout, diagnostics
+// are written there in quickfix format instead. It returns whether every step
+// passed with no diagnostics left after filtering.
+func (c *Controller) RunOnce() (bool, error) {
+  if (c.Configuration == nil) {
+    return false, ErrNoConfig
+  }
+
+  pipelines, limits, err := c.readSettings()
+  if err != nil {
+    return false, err
+  }
+  rc := newRunnerChannels(pipelines)
+  rc.startRoutines()
+  var all [][]backend.StepResult
+  var started time.Time
+  runPipelines(context.Background(), pipelines, NewRunnerFuncs(
+    func(results [][]backend.StepResult, start time.Time) {
+      all, started = results, start
+    }, c.Log, func(op string) {}, nil), limits, rc)
+  rc.quitRoutines()
+
+  clean := true
+  export := make([]backend.CompileLine, 0)
+  for p, diagnostics := range c.parseResults(all) {
+    clean = clean && backend.FailedStep(all[p]) == nil
+    export = append(export, joinDiagnostics(diagnostics)...)
+  }
+  export = append(export, c.readReports(started)...)
+  clean = clean && len(backend.FilterSeverity(export, c.minSeverity)) == 0
+
+  stdout := false
+  for _, name := range c.exports {
+    stdout = stdout || name == "-"
+  }
+  if !stdout {
+    if err := backend.Export("quickfix", "-", export); err != nil {
+      return false, err
+    }
+  }
+  c.export(export)
+  return clean, nil
+}
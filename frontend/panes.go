@@ -0,0 +1,175 @@
+package frontend
+
+import (
+  "github.com/jroimartin/gocui"
+  "github.com/MikeKneeB/coco/backend"
+  "fmt"
+  "strings"
+  "sync"
+  "time"
+)
+
+// pane holds the results of one pipeline, each pipeline running at once has
+// its own pane shown as a tab of the normal view.
+type pane struct {
+  pipeline backend.Pipeline
+  // Step whose go test -json output is rendered as a test tree, or -1
+  testStep int
+
+  // Most recent result, kept so it can be redrawn
+  results []backend.StepResult
+  // Diagnostics from the last run and how they differ from the run before.
+  // Reports belong to no one pane, so are kept by the controller.
+  diagnostics []backend.CompileLine
+  diff *backend.DiagnosticDiff
+  // The same diagnostics, split by the step which found them
+  steps [][]backend.CompileLine
+
+  // Output of the run in progress and the diagnostics found in it so far.
+  // These are written by the runner as steps produce output, so are guarded
+  // by mutex, and the gui only reads them.
+  mutex sync.Mutex
+  running bool
+  // When the run in progress started
+  run time.Time
+  live []liveLine
+  found []backend.CompileLine
+  // A redraw has been asked for and not yet drawn
+  redrawing bool
+}
+
+// liveLine is a line of output from the run in progress, or the header of a
+// step.
+type liveLine struct {
+  header string
+  // Marks which step of a graph wrote the line
+  prefix string
+  line backend.OutputLine
+}
+
+func newPane(p backend.Pipeline) *pane {
+  pn := &pane{pipeline: p, testStep: -1}
+  for i, step := range p.Steps {
+    if backend.IsGoTestJSON(step.Script) {
+      pn.testStep = i
+    }
+  }
+  return pn
+}
+
+// start clears the pane for the run which started at run, unless it is
+// already showing it.
+func (pn *pane) start(run time.Time) {
+  pn.mutex.Lock()
+  defer pn.mutex.Unlock()
+  if !pn.run.Equal(run) {
+    pn.run = run
+    pn.running = true
+    pn.live = nil
+    pn.found = nil
+  }
+}
+
+// finish stops showing the output of the run which started at run, unless
+// another run has started since.
+func (pn *pane) finish(run time.Time) {
+  pn.mutex.Lock()
+  defer pn.mutex.Unlock()
+  if pn.run.Equal(run) {
+    pn.running = false
+    pn.live = nil
+  }
+}
+
+// addLines appends output to the run in progress, returning false once the
+// run has finished.
+func (pn *pane) addLines(lines ...liveLine) bool {
+  pn.mutex.Lock()
+  defer pn.mutex.Unlock()
+  if pn.running {
+    pn.live = append(pn.live, lines...)
+  }
+  return pn.running
+}
+
+// addFound appends diagnostics to those found in the run in progress,
+// returning how many there are, or -1 once the run has finished.
+func (pn *pane) addFound(cls []backend.CompileLine) int {
+  pn.mutex.Lock()
+  defer pn.mutex.Unlock()
+  if !pn.running {
+    return -1
+  }
+  pn.found = append(pn.found, cls...)
+  return len(pn.found)
+}
+
+// progress returns the state of the run in progress. The slices are only
+// ever appended to, so may be read while the run carries on.
+func (pn *pane) progress() (bool, []liveLine, []backend.CompileLine) {
+  pn.mutex.Lock()
+  defer pn.mutex.Unlock()
+  return pn.running, pn.live, pn.found
+}
+
+// otherDiagnostics returns the diagnostics of every step but the test step.
+func (pn *pane) otherDiagnostics() []backend.CompileLine {
+  others := make([]backend.CompileLine, 0)
+  for i, cls := range pn.steps {
+    if i != pn.testStep {
+      others = append(others, cls...)
+    }
+  }
+  return others
+}
+
+// name labels the pane's tab, with the state of its last or current run.
+func (pn *pane) name() string {
+  name := pn.pipeline.Name
+  running, _, found := pn.progress()
+  switch {
+  case running:
+    return fmt.Sprintf("%s: running, %d found", name, len(found))
+  case backend.FailedStep(pn.results) != nil:
+    return name + ": failed"
+  case pn.results != nil:
+    return name + ": ok"
+  }
+  return name
+}
+
+// paneTitle lists the panes for the normal view's title, marking the current
+// one. A lone pane has no title.
+func paneTitle(panes []*pane, current int) string {
+  if len(panes) == 1 {
+    return ""
+  }
+  tabs := make([]string, 0, len(panes))
+  for i, pn := range panes {
+    if i == current {
+      tabs = append(tabs, "[" + pn.name() + "]")
+    } else {
+      tabs = append(tabs, " " + pn.name() + " ")
+    }
+  }
+  return strings.Join(tabs, "") + " (tab to switch)"
+}
+
+// setTitle updates the normal view's tabs, must be called from the gui
+// goroutine.
+func (c *Controller) setTitle(g *gocui.Gui) error {
+  v, err := g.View("normal")
+  if err != nil {
+    return err
+  }
+  v.Title = paneTitle(c.panes, c.current)
+  return nil
+}
+
+func (c *Controller) nextPane(g *gocui.Gui, v *gocui.View) error {
+  if len(c.panes) < 2 {
+    return nil
+  }
+  c.current = (c.current + 1) % len(c.panes)
+  return c.renderOutput(g)
+}
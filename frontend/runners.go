@@ -2,20 +2,32 @@ package frontend
 
 import (
   "github.com/MikeKneeB/coco/backend"
+  "context"
   "errors"
+  "fmt"
   "os/exec"
   "time"
   "github.com/fsnotify/fsnotify"
   "path/filepath"
   "os"
   "strings"
+  "sync"
 )
 
 var ErrNoOuputFn error = errors.New("Output Function not defined!")
 
-type OutputFunction func(out string, ret_code int)
+// Called once every pipeline of a run has finished, with each one's results
+// and when the run started
+type OutputFunction func(results [][]backend.StepResult, started time.Time)
 type LogFunction func(items ...interface{})
 type OpFunction func(op string)
+// Receives a run's output a line at a time as the command produces it
+type LiveOutput interface {
+  Lines(lines []backend.OutputLine)
+  Close() error
+}
+// Called as each step starts, with when the run it belongs to started
+type StreamFunction func(pipeline, step int, started time.Time) LiveOutput
 
 type RunnerSignal int
 
@@ -31,23 +43,359 @@ type runnerFuncs struct {
   outputFunc OutputFunction
   logFunc LogFunction
   opFunc OpFunction
+  streamFunc StreamFunction
 }
 
-func NewRunnerFuncs(of OutputFunction, lf LogFunction, op OpFunction) runnerFuncs {
-  return runnerFuncs{of, lf, op}
+func NewRunnerFuncs(of OutputFunction, lf LogFunction, op OpFunction,
+  sf StreamFunction) runnerFuncs {
+  return runnerFuncs{of, lf, op, sf}
 }
 
-// Channels compositor
-type runnerChannels struct {
-  sigChan chan RunnerSignal
+// result waits for the running step to finish, copying its output to a new
+// stream while it runs if there is a stream function.
+func (rf runnerFuncs) result(res <-chan backend.RoutineOut, pipeline,
+  step int, started time.Time) (*backend.CommandOutput, error) {
+  if rf.streamFunc == nil {
+    return backend.RoutineResult(res, nil)
+  }
+  stream := rf.streamFunc(pipeline, step, started)
+  defer stream.Close()
+  return backend.RoutineResult(res, func(chunk *backend.CommandOutput) {
+    stream.Lines(chunk.Lines)
+  })
+}
+
+// A function which runs a step, returning false if the run was stopped and
+// the rest of the pipeline should be abandoned
+type stepFunction func(i int, step backend.Step) (*backend.CommandOutput, error,
+  bool)
+
+// executingOp describes step i of p in the operation bar while it runs.
+func executingOp(p backend.Pipeline, i int) string {
+  op := "EXECUTING"
+  if len(p.Steps) != 1 {
+    op = fmt.Sprintf("EXECUTING %s (%d/%d)", p.Steps[i].Name, i + 1,
+      len(p.Steps))
+  }
+  if p.Name != "" {
+    op = p.Name + ": " + op
+  }
+  return op
+}
+
+// runPipeline runs each step of p with run, skipping the rest after a failure
+// if p stops on failure, or runs its steps as a graph. It returns false if a
+// step was stopped, and shows what the pipeline is doing with status.
+func (rf runnerFuncs) runPipeline(p backend.Pipeline, run stepFunction,
+  status OpFunction) ([]backend.StepResult, bool) {
+  if p.Graph() {
+    return rf.runGraph(p, run, status)
+  }
+  results := make([]backend.StepResult, 0, len(p.Steps))
+  failed := false
+  for i, step := range p.Steps {
+    if failed && p.StopOnFailure {
+      results = append(results, backend.StepResult{Name: step.Name,
+        Skipped: true})
+      continue
+    }
+    status(executingOp(p, i))
+    result, ok := rf.runStep(p, i, run)
+    if !ok {
+      return nil, false
+    }
+    failed = failed || result.Failed()
+    results = append(results, result)
+  }
+  return results, true
+}
+
+// stepLabel names step i of p in the log.
+func stepLabel(p backend.Pipeline, i int) string {
+  label := "Command"
+  if len(p.Steps) != 1 {
+    label = "Step " + p.Steps[i].Name
+  }
+  if p.Name != "" {
+    label = p.Name + ": " + label
+  }
+  return label
+}
+
+// runStep runs step i of p, logging the command and how it exited.
+func (rf runnerFuncs) runStep(p backend.Pipeline, i int,
+  run stepFunction) (backend.StepResult, bool) {
+  step := p.Steps[i]
+  label := stepLabel(p, i)
+  rf.logFunc(label + " run: ", step.Command)
+  output, err, ok := run(i, step)
+  if !ok {
+    return backend.StepResult{}, false
+  }
+  result := backend.StepResult{Name: step.Name, Output: output}
+  if err != nil {
+    exit_err, ok := err.(*exec.ExitError)
+    if !ok {
+      rf.logFunc(err)
+      result.Err = err
+      result.ReturnCode = -1
+    } else {
+      result.ReturnCode = exit_err.ExitCode()
+    }
+  }
+  if result.Err == nil {
+    rf.logFunc(label + " exited: ", result.ReturnCode)
+  }
+  return result, true
+}
+
+// graphOp describes a graph in the operation bar, naming the steps running.
+func graphOp(p backend.Pipeline, states []backend.StepState) string {
+  running := make([]string, 0)
+  done := 0
+  for i, state := range states {
+    if state == backend.StepRunning {
+      running = append(running, p.Steps[i].Name)
+    } else if state != backend.StepWaiting {
+      done++
+    }
+  }
+  op := fmt.Sprintf("EXECUTING %s (%d/%d done)", strings.Join(running, ", "),
+    done, len(p.Steps))
+  if p.Name != "" {
+    op = p.Name + ": " + op
+  }
+  return op
+}
+
+// runGraph runs each step of p once the steps it needs have passed, with
+// steps that are ready at the same time running at once. Steps needing a
+// failed step are skipped, along with the steps needing those.
+func (rf runnerFuncs) runGraph(p backend.Pipeline, run stepFunction,
+  status OpFunction) ([]backend.StepResult, bool) {
+  type finished struct {
+    i int
+    result backend.StepResult
+    ok bool
+  }
+  results := make([]backend.StepResult, len(p.Steps))
+  states := make([]backend.StepState, len(p.Steps))
+  done := make(chan finished)
+  running := 0
+  stopped := false
+  label := "Steps"
+  if p.Name != "" {
+    label = p.Name + ": " + label
+  }
+  for {
+    if !stopped {
+      for _, i := range p.Schedule(states) {
+        states[i] = backend.StepRunning
+        running++
+        go func(i int) {
+          result, ok := rf.runStep(p, i, run)
+          done <- finished{i, result, ok}
+        }(i)
+      }
+    }
+    if running == 0 {
+      break
+    }
+    status(graphOp(p, states))
+    f := <- done
+    running--
+    if !f.ok {
+      // Let the other running steps stop before giving up on the run
+      stopped = true
+      continue
+    }
+    results[f.i] = f.result
+    states[f.i] = backend.StepPassed
+    if f.result.Failed() {
+      states[f.i] = backend.StepFailed
+    }
+    if !stopped {
+      p.Schedule(states)
+      rf.logFunc(label + ": ", p.GraphStatus(states))
+    }
+  }
+  if stopped {
+    return nil, false
+  }
+  for i, state := range states {
+    if state == backend.StepSkipped {
+      results[i] = backend.StepResult{Name: p.Steps[i].Name, Skipped: true}
+    }
+  }
+  return results, true
+}
+
+// opTracker combines the operations of pipelines running at once into the
+// operation bar.
+type opTracker struct {
+  mutex sync.Mutex
+  ops []string
+  opFunc OpFunction
+}
+
+func (t *opTracker) set(i int, op string) {
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+  t.ops[i] = op
+  running := make([]string, 0, len(t.ops))
+  for _, op := range t.ops {
+    if op != "" {
+      running = append(running, op)
+    }
+  }
+  t.opFunc(strings.Join(running, " | "))
+}
+
+// runPipelines runs every pipeline at once, each on its own routine, passing
+// on all their results once the last one finishes. It returns false if ctx
+// was cancelled before they all finished.
+func runPipelines(ctx context.Context, pipelines []backend.Pipeline,
+  rf runnerFuncs, rl runLimits, rc runnerChannels) bool {
+  ops := &opTracker{ops: make([]string, len(pipelines)), opFunc: rf.opFunc}
+  failed := make([]string, len(pipelines))
+  all := make([][]backend.StepResult, len(pipelines))
+  started := time.Now()
+  var wg sync.WaitGroup
+  for i, p := range pipelines {
+    wg.Add(1)
+    go func(i int, p backend.Pipeline) {
+      defer wg.Done()
+      results, ok := rf.runPipeline(p, func(step int, s backend.Step) (
+        *backend.CommandOutput, error, bool) {
+        if ctx.Err() != nil {
+          return nil, nil, false
+        }
+        routine := rc.routines[i][step]
+        limits := rl.forPipeline(p)
+        runnable, step_ctx, cancel := limits.runnable(ctx, &s.Command)
+        defer cancel()
+        routine.comChan <- runnable
+        output, err := rf.result(routine.resChan, i, step, started)
+        if ctx.Err() != nil {
+          return nil, nil, false
+        }
+        limits.timedOut(step_ctx, output, rf.logFunc)
+        return output, err, true
+      }, func(op string) {
+        ops.set(i, op)
+      })
+      if !ok {
+        return
+      }
+      all[i] = results
+      if f := backend.FailedStep(results); f != nil {
+        failed[i] = strings.TrimSpace(p.Name + " " + f.Name)
+        if len(p.Steps) == 1 && p.Name != "" {
+          failed[i] = p.Name
+        }
+      }
+      ops.set(i, "")
+    }(i, p)
+  }
+  wg.Wait()
+  if ctx.Err() != nil {
+    return false
+  }
+  rf.outputFunc(all, started)
+  failures := make([]string, 0)
+  for _, f := range failed {
+    if f != "" {
+      failures = append(failures, f)
+    }
+  }
+  if len(failures) != 0 && (len(pipelines) != 1 ||
+    len(pipelines[0].Steps) != 1) {
+    rf.opFunc("IDLE (" + strings.Join(failures, ", ") + " failed)")
+  } else {
+    rf.opFunc("IDLE")
+  }
+  return true
+}
+
+// Limits compositor, how long a run may take and how long it then has to exit
+// after being asked to stop. Each pipeline has its own time limit.
+type runLimits struct {
+  limit time.Duration
+  grace time.Duration
+}
+
+func NewRunLimits(grace time.Duration) runLimits {
+  return runLimits{0, grace}
+}
+
+// forPipeline returns the limits for the steps of p.
+func (l runLimits) forPipeline(p backend.Pipeline) runLimits {
+  return runLimits{p.Timeout, l.grace}
+}
+
+// runnable creates the command for a run, which is stopped when the returned
+// context is cancelled, parent is done or its time limit passes.
+func (l runLimits) runnable(parent context.Context, c *backend.CommandDef) (
+  *exec.Cmd, context.Context, context.CancelFunc) {
+  ctx, cancel := context.WithCancel(parent)
+  if l.limit > 0 {
+    ctx, cancel = context.WithTimeout(parent, l.limit)
+  }
+  return c.MakeCancellableRunnable(ctx, l.grace), ctx, cancel
+}
+
+// timedOut marks output from a run stopped by its time limit.
+func (l runLimits) timedOut(ctx context.Context, output *backend.CommandOutput,
+  log LogFunction) {
+  if ctx.Err() == context.DeadlineExceeded && output != nil {
+    output.Timeout = l.limit
+    log("Command timed out after ", l.limit)
+  }
+}
+
+// Channels for one step's ContinualRoutine
+type routineChannels struct {
   comChan chan *exec.Cmd
   resChan chan backend.RoutineOut
   quitChan chan bool
 }
 
-func newRunnerChannels() runnerChannels {
-  return runnerChannels{make(chan RunnerSignal), make(chan *exec.Cmd),
-    make(chan backend.RoutineOut), make(chan bool)}
+// Channels compositor
+type runnerChannels struct {
+  sigChan chan RunnerSignal
+  // A routine for each step of each pipeline, so steps of a graph can run
+  // at once
+  routines [][]routineChannels
+}
+
+func newRunnerChannels(pipelines []backend.Pipeline) runnerChannels {
+  rc := runnerChannels{sigChan: make(chan RunnerSignal)}
+  for _, p := range pipelines {
+    routines := make([]routineChannels, 0, len(p.Steps))
+    for range p.Steps {
+      routines = append(routines, routineChannels{make(chan *exec.Cmd),
+        make(chan backend.RoutineOut), make(chan bool)})
+    }
+    rc.routines = append(rc.routines, routines)
+  }
+  return rc
+}
+
+func (rc runnerChannels) startRoutines() {
+  for _, routines := range rc.routines {
+    for _, routine := range routines {
+      go backend.ContinualRoutine(routine.resChan, routine.quitChan,
+        routine.comChan)
+    }
+  }
+}
+
+func (rc runnerChannels) quitRoutines() {
+  for _, routines := range rc.routines {
+    for _, routine := range routines {
+      routine.quitChan <- true
+    }
+  }
 }
 
 type Runner interface {
@@ -58,19 +406,22 @@ type Runner interface {
 type TimeRunner struct {
   // Actual struct data
   timeOut time.Duration
-  command backend.CommandDef
+  pipelines []backend.Pipeline
+  runLimits
   // Callbacks
   runnerFuncs
   // Channels
   runnerChannels
 }
 
-func NewTimeRunner(to float64, c backend.CommandDef, rf runnerFuncs) *TimeRunner {
+func NewTimeRunner(to float64, rl runLimits, p []backend.Pipeline,
+  rf runnerFuncs) *TimeRunner {
   r := new(TimeRunner)
   r.timeOut = time.Duration(to * 1000000000) * time.Nanosecond
-  r.command = c
+  r.runLimits = rl
+  r.pipelines = p
   r.runnerFuncs = rf
-  r.runnerChannels = newRunnerChannels()
+  r.runnerChannels = newRunnerChannels(p)
   return r
 }
 
@@ -86,48 +437,35 @@ func (r *TimeRunner) Start() error {
 
 func (r *TimeRunner) loop() {
 
-  go backend.ContinualRoutine(r.resChan, r.quitChan, r.comChan)
+  r.startRoutines()
 
   for {
     // Part A - wait for signals (or t-out)
-    r.wait()
+    if !r.wait() {
+      return
+    }
     // Part B - send result back
     r.send()
     // End loop
   }
 }
 
-func (r *TimeRunner) wait() {
+// wait returns whether to run the command, or false to quit.
+func (r *TimeRunner) wait() bool {
   select {
   case sig := <- r.sigChan:
     if sig == Quit {
-      r.quitChan <- true
-      return
-    } else if sig == ForceUpdate {
-      r.comChan <- r.command.MakeRunnable()
+      r.quitRoutines()
+      return false
     }
   case <- time.After(r.timeOut):
-    r.comChan <- r.command.MakeRunnable()
   }
+  return true
 }
 
 func (r *TimeRunner) send() {
-  r.logFunc("Run command: ", r.command)
-  r.opFunc("EXECUTING")
-  output, err := (<- r.resChan)()
-  if err != nil {
-    exit_err, ok := err.(*exec.ExitError)
-    if !ok {
-      r.logFunc(err)
-    } else {
-      r.logFunc("Command exited: ", exit_err.ExitCode())
-      r.outputFunc(output, exit_err.ExitCode())
-    }
-  } else {
-    r.logFunc("Command Exited: ", 0)
-    r.outputFunc(output, 0)
-  }
-  r.opFunc("IDLE")
+  runPipelines(context.Background(), r.pipelines, r.runnerFuncs, r.runLimits,
+    r.runnerChannels)
 }
 
 func (r *TimeRunner) Signal(sig RunnerSignal) {
@@ -138,7 +476,9 @@ type FSRunner struct {
   // Actual data
   root string
   exts []string
-  command backend.CommandDef
+  onChange backend.ChangePolicy
+  pipelines []backend.Pipeline
+  runLimits
   // Callbacks
   runnerFuncs
   // Signals
@@ -147,14 +487,16 @@ type FSRunner struct {
   watcher *fsnotify.Watcher
 }
 
-func NewFSRunner(root string, exts []string, c backend.CommandDef,
-  rf runnerFuncs) (*FSRunner, error) {
+func NewFSRunner(root string, exts []string, on_change backend.ChangePolicy,
+  rl runLimits, p []backend.Pipeline, rf runnerFuncs) (*FSRunner, error) {
   r := new(FSRunner)
   r.root = root
   r.exts = exts
-  r.command = c
+  r.onChange = on_change
+  r.runLimits = rl
+  r.pipelines = p
   r.runnerFuncs = rf
-  r.runnerChannels = newRunnerChannels()
+  r.runnerChannels = newRunnerChannels(p)
   var err error
   r.watcher, err = fsnotify.NewWatcher()
   if err != nil {
@@ -187,7 +529,7 @@ const (
 )
 
 func (r *FSRunner) loop() {
-  go backend.ContinualRoutine(r.resChan, r.quitChan, r.comChan)
+  r.startRoutines()
 
   check := (len(r.exts) != 0)
   send_command := fsSend
@@ -195,7 +537,11 @@ func (r *FSRunner) loop() {
   for {
     // Part A - send command and respond
     if send_command == fsSend {
-      r.send()
+      send_command = r.send(check)
+      // Changes while running may have asked for another run already
+      if send_command != fsContinue {
+        continue
+      }
     } else if send_command == fsQuit {
       return
     }
@@ -209,14 +555,14 @@ func (r *FSRunner) wait(check bool) fsStatus {
   select {
   case sig := <- r.sigChan:
     if sig == Quit {
-      r.quitChan <- true
+      r.quitRoutines()
       return fsQuit
     } else if sig == ForceUpdate {
       return fsSend
     }
   case event, ok := <- r.watcher.Events:
     if !ok {
-      r.quitChan <- true
+      r.quitRoutines()
       return fsContinue
     }
     if !(event.Op == fsnotify.Chmod) {
@@ -230,31 +576,79 @@ func (r *FSRunner) wait(check bool) fsStatus {
     if ok {
       r.logFunc(error)
     }
-    r.quitChan <- true
+    r.quitRoutines()
     return fsQuit
   }
   return fsQuit
 }
 
-func (r *FSRunner) send() {
-  r.logFunc("Run command: ", r.command)
-  r.opFunc("EXECUTING")
-  r.comChan <- r.command.MakeRunnable()
-
-  output, err := (<- r.resChan)()
-  if err != nil {
-    exit_err, ok := err.(*exec.ExitError)
-    if !ok {
-      r.logFunc(err)
-    } else {
-      r.logFunc("Command exited: ", exit_err.ExitCode())
-      r.outputFunc(output, exit_err.ExitCode())
+// send runs the pipelines, watching for changes while they run. Whether they
+// cause another run depends on the change policy.
+func (r *FSRunner) send(check bool) fsStatus {
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+  done := make(chan bool)
+  go func() {
+    runPipelines(ctx, r.pipelines, r.runnerFuncs, r.runLimits,
+      r.runnerChannels)
+    close(done)
+  }()
+
+  next := fsContinue
+  for running := true; running; {
+    select {
+    case <- done:
+      running = false
+    case sig := <- r.sigChan:
+      if sig == Quit {
+        cancel()
+        <- done
+        r.quitRoutines()
+        return fsQuit
+      } else if sig == ForceUpdate {
+        next = r.change(cancel)
+      }
+    case event, ok := <- r.watcher.Events:
+      if !ok {
+        cancel()
+        <- done
+        r.quitRoutines()
+        return fsQuit
+      }
+      if event.Op != fsnotify.Chmod && (!check ||
+        r.checkUpdate(event) == fsSend) {
+        next = r.change(cancel)
+      }
+    case error, ok := <- r.watcher.Errors:
+      if ok {
+        r.logFunc(error)
+      }
+      cancel()
+      <- done
+      r.quitRoutines()
+      return fsQuit
     }
-  } else {
-    r.logFunc("Command exited: ", 0)
-    r.outputFunc(output, 0)
   }
-  r.opFunc("IDLE")
+
+  if ctx.Err() == context.Canceled {
+    r.logFunc("Command stopped for changes")
+  }
+  return next
+}
+
+// change handles a change arriving while the command runs, returning whether
+// to run again when it finishes.
+func (r *FSRunner) change(cancel context.CancelFunc) fsStatus {
+  switch r.onChange {
+  case backend.RestartOnChange:
+    r.opFunc("RESTARTING")
+    cancel()
+    return fsSend
+  case backend.IgnoreChanges:
+    return fsContinue
+  default:
+    return fsSend
+  }
 }
 
 func (r *FSRunner) checkUpdate(e fsnotify.Event) fsStatus {
@@ -0,0 +1,62 @@
+package frontend
+
+import (
+  "github.com/jroimartin/gocui"
+  "github.com/MikeKneeB/coco/backend"
+  "fmt"
+  "strings"
+)
+
+var statusColours = map[backend.TestStatus]string{
+  backend.TestRunning: "\033[34m",
+  backend.TestPass: "\033[32;1m",
+  backend.TestFail: "\033[31;1m",
+  backend.TestSkip: "\033[33m",
+}
+
+func statusString(s backend.TestStatus) string {
+  return statusColours[s] + s.String() + "\033[0m"
+}
+
+// renderTestReport draws the package and test tree from go test -json output.
+// Passing packages are summarised on one line, failing packages list each of
+// their tests along with the output of those which failed.
+func renderTestReport(v *gocui.View, report *backend.TestReport) {
+  if report.Other != "" {
+    fmt.Fprint(v, report.Other)
+  }
+  for _, pkg := range report.Packages {
+    fmt.Fprintf(v, "%s %s (%.2fs)", statusString(pkg.Status), pkg.Name,
+      pkg.Elapsed.Seconds())
+    if pkg.Status != backend.TestFail {
+      fmt.Fprintf(v, " %d passed, %d skipped\n", pkg.Count(backend.TestPass),
+        pkg.Count(backend.TestSkip))
+      continue
+    }
+    fmt.Fprintf(v, " %d passed, %d failed, %d skipped\n",
+      pkg.Count(backend.TestPass), pkg.Count(backend.TestFail),
+      pkg.Count(backend.TestSkip))
+    for _, t := range pkg.Tests {
+      renderTestResult(v, t, 1)
+    }
+  }
+}
+
+func renderTestResult(v *gocui.View, t *backend.TestResult, depth int) {
+  indent := strings.Repeat("  ", depth)
+  fmt.Fprintf(v, "%s%s %s (%.2fs)\n", indent, statusString(t.Status), t.Name,
+    t.Elapsed.Seconds())
+  if t.Status == backend.TestFail && len(t.Subtests) == 0 {
+    for _, line := range strings.Split(strings.TrimRight(t.Output, "\n"), "\n") {
+      // The run and result lines repeat what the tree already shows
+      trimmed := strings.TrimSpace(line)
+      if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+        continue
+      }
+      fmt.Fprintln(v, indent + "  " + strings.TrimSpace(line))
+    }
+  }
+  for _, sub := range t.Subtests {
+    renderTestResult(v, sub, depth + 1)
+  }
+}